@@ -4,6 +4,8 @@ package api
 import (
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -41,31 +43,138 @@ func API(newPath string) {
 	r.Get("/", health)
 	r.Get("/health", health)
 	r.Get("/balance", balance)
+	r.Get("/tree", tree)
+	r.Get("/balance-sheet", balanceSheet)
+	r.Get("/income-statement", incomeStatement)
 
 	log.Fatal().Err(http.ListenAndServe(":"+port, r))
 }
 
 func balance(w http.ResponseWriter, r *http.Request) {
 	log.Debug().Str("Method", r.Method).Str("URL", r.URL.String()).Msg("Request")
-	file, err := os.Open(path)
+	ledger := bean.NewLedger(false)
+	res := ledger.LoadFileAll(path)
+	if res.HasErrors() {
+		re.JSON(w, http.StatusUnprocessableEntity, map[string][]bean.Error{"errors": res.Errors})
+		return
+	}
+	date := time.Now()
+	ccy := bean.Ccy(r.URL.Query().Get("currency"))
+	if ccy != "" {
+		value := r.URL.Query().Get("valuation")
+		if value == "" {
+			value = string(bean.ValueMarket)
+		}
+		valuation, err := bean.NewValuation(value)
+		if err != nil {
+			re.JSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		bals, err := ledger.GetBalancesIn(date, ccy, valuation)
+		if err != nil {
+			panic(err)
+		}
+		re.JSON(w, http.StatusOK, bals)
+		return
+	}
+	bals, err := ledger.GetBalances(date)
 	if err != nil {
 		panic(err)
 	}
-	defer file.Close()
+	re.JSON(w, http.StatusOK, bals)
+}
+
+func tree(w http.ResponseWriter, r *http.Request) {
+	log.Debug().Str("Method", r.Method).Str("URL", r.URL.String()).Msg("Request")
+	depth := 0
+	if d := r.URL.Query().Get("depth"); d != "" {
+		var err error
+		depth, err = strconv.Atoi(d)
+		if err != nil {
+			re.JSON(w, http.StatusBadRequest, map[string]string{"error": "depth must be a number"})
+			return
+		}
+	}
 	ledger := bean.NewLedger(false)
-	_, err = ledger.Load(file)
+	res := ledger.LoadFileAll(path)
+	if res.HasErrors() {
+		re.JSON(w, http.StatusUnprocessableEntity, map[string][]bean.Error{"errors": res.Errors})
+		return
+	}
+	accTree, err := ledger.AccountTree(time.Now())
 	if err != nil {
 		panic(err)
 	}
-	date := time.Now()
-	bals, err := ledger.GetBalances(date)
+	accTree = accTree.Truncate(depth)
+	if r.URL.Query().Get("format") == "text" {
+		var b strings.Builder
+		if err := bean.PrintAccountTree(&b, accTree); err != nil {
+			panic(err)
+		}
+		re.Text(w, http.StatusOK, b.String())
+		return
+	}
+	re.JSON(w, http.StatusOK, accTree)
+}
+
+func balanceSheet(w http.ResponseWriter, r *http.Request) {
+	log.Debug().Str("Method", r.Method).Str("URL", r.URL.String()).Msg("Request")
+	ledger, opts, ok := loadReport(w, r)
+	if !ok {
+		return
+	}
+	bs, err := ledger.BalanceSheet(opts)
 	if err != nil {
 		panic(err)
 	}
+	re.JSON(w, http.StatusOK, bs)
+}
+
+func incomeStatement(w http.ResponseWriter, r *http.Request) {
+	log.Debug().Str("Method", r.Method).Str("URL", r.URL.String()).Msg("Request")
+	ledger, opts, ok := loadReport(w, r)
+	if !ok {
+		return
+	}
+	is, err := ledger.IncomeStatement(opts)
 	if err != nil {
 		panic(err)
 	}
-	re.JSON(w, http.StatusOK, bals)
+	re.JSON(w, http.StatusOK, is)
+}
+
+// loadReport loads the ledger and reads the ReportOptions from the
+// from, to (default now), currency and valuation parameters.
+// It writes an error response and returns false if either fails.
+func loadReport(w http.ResponseWriter, r *http.Request) (*bean.Ledger, bean.ReportOptions, bool) {
+	params := r.URL.Query()
+	opts := bean.ReportOptions{To: time.Now(), Ccy: bean.Ccy(params.Get("currency"))}
+	for name, date := range map[string]*time.Time{"from": &opts.From, "to": &opts.To} {
+		if params.Get(name) == "" {
+			continue
+		}
+		t, err := time.Parse(time.DateOnly, params.Get(name))
+		if err != nil {
+			re.JSON(w, http.StatusBadRequest, map[string]string{"error": name + " must be a date (YYYY-MM-DD)"})
+			return nil, opts, false
+		}
+		*date = t
+	}
+	if value := params.Get("valuation"); value != "" {
+		valuation, err := bean.NewValuation(value)
+		if err != nil {
+			re.JSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return nil, opts, false
+		}
+		opts.Valuation = valuation
+	}
+	ledger := bean.NewLedger(false)
+	res := ledger.LoadFileAll(path)
+	if res.HasErrors() {
+		re.JSON(w, http.StatusUnprocessableEntity, map[string][]bean.Error{"errors": res.Errors})
+		return nil, opts, false
+	}
+	return ledger, opts, true
 }
 
 func health(w http.ResponseWriter, r *http.Request) {
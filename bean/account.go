@@ -1,10 +1,14 @@
 package bean
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"sort"
+	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
 // Ccy is a currency like USD or GOOG or WHATEVER
@@ -27,7 +31,10 @@ type AccountEvent struct {
 	Date    time.Time
 	Open    bool
 	Account Account
-	Ccy     Ccy
+	Ccys    []Ccy   // allowed currencies, any if empty
+	Booking Booking // empty if not given
+	Meta    Meta
+	Pos     Pos
 }
 
 func (ae AccountEvent) String() string {
@@ -35,34 +42,75 @@ func (ae AccountEvent) String() string {
 	if ae.Open {
 		openOrClose = "open"
 	}
-	return fmt.Sprintf("%s %s %s %s\n", ae.Date.Format(time.DateOnly), openOrClose, ae.Account.Name, ae.Ccy)
+	ccys := make([]string, len(ae.Ccys))
+	for i, ccy := range ae.Ccys {
+		ccys[i] = string(ccy)
+	}
+	if ae.Booking != "" {
+		return fmt.Sprintf("%s %s %s %s %q\n", ae.Date.Format(time.DateOnly), openOrClose, ae.Account.Name, strings.Join(ccys, ","), ae.Booking)
+	}
+	return fmt.Sprintf("%s %s %s %s\n", ae.Date.Format(time.DateOnly), openOrClose, ae.Account.Name, strings.Join(ccys, ","))
 }
 
 // newAccountEvent creates an AccountEvent from a Directive
 func newAccountEvent(directive Directive) (AccountEvent, error) {
-	line := directive.Lines[0] // TODO include metadata lines
-	log.Println("newAccountEvent", line.Tokens[0].Text)
+	line := directive.Lines[0]
+	if debug {
+		log.Println("newAccountEvent", line.Tokens[0].Text)
+	}
 	tokens := line.Tokens
+	if len(tokens) < 3 {
+		return AccountEvent{}, fmt.Errorf("%s must have an account", tokens[1].Text)
+	}
 	date, err := getDate(tokens[0].Text)
 	if err != nil {
 		return AccountEvent{}, fmt.Errorf("in newAccountEvent: %w", err)
 	}
 	open := tokens[1].Text == "open"
 	account := tokens[2].Text
-	var ccy Ccy
-	if len(tokens) >= 4 {
-		// TODO should return err if ccy provided on close
-		ccy = Ccy(tokens[3].Text)
+	// currencies are a comma-separated list, followed by the quoted booking method
+	var ccys []Ccy
+	rest := tokens[3:]
+	for len(rest) > 0 && rest[0].Kind != TokenString {
+		if rest[0].Kind == TokenComma {
+			return AccountEvent{}, fmt.Errorf("currency missing before comma")
+		}
+		ccys = append(ccys, Ccy(rest[0].Text))
+		rest = rest[1:]
+		if len(rest) == 0 || rest[0].Kind != TokenComma {
+			break
+		}
+		rest = rest[1:]
+		if len(rest) == 0 || rest[0].Kind == TokenString {
+			return AccountEvent{}, fmt.Errorf("currency missing after comma")
+		}
+	}
+	var booking Booking
+	if len(rest) > 0 && rest[0].Kind == TokenString {
+		booking, err = newBooking(rest[0].Text)
+		if err != nil {
+			return AccountEvent{}, fmt.Errorf("in newAccountEvent: %w", err)
+		}
+		rest = rest[1:]
 	}
-	if len(tokens) >= 5 {
-		// TODO handle additional currencies
-		log.Println("ignoring extra open/close tokens")
+	if len(rest) > 0 {
+		return AccountEvent{}, fmt.Errorf("unexpected token in %s: %s", tokens[1].Text, rest[0].Text)
+	}
+	if !open && (len(ccys) > 0 || booking != "") {
+		return AccountEvent{}, fmt.Errorf("close cannot have currencies or a booking method")
+	}
+	meta, err := newMeta(directive.Lines[1:])
+	if err != nil {
+		return AccountEvent{}, fmt.Errorf("in newAccountEvent: %w", err)
 	}
 	accountEvent := AccountEvent{
 		Date:    date,
 		Open:    open,
 		Account: Account{AccountName(account)},
-		Ccy:     ccy,
+		Ccys:    ccys,
+		Booking: booking,
+		Meta:    meta,
+		Pos:     directive.Pos(),
 	}
 	return accountEvent, nil
 }
@@ -74,7 +122,7 @@ type AccountTimeLine = map[AccountName][]AccountEvent
 // NewAccountTimeLine maps the AccountEvents by AccountName
 func NewAccountTimeLine(aes []AccountEvent) (AccountTimeLine, error) {
 	atl := make(AccountTimeLine, len(aes))
-	sort.Slice(aes, func(i, j int) bool {
+	sort.SliceStable(aes, func(i, j int) bool {
 		return aes[i].Date.Before(aes[j].Date)
 	})
 	for _, ae := range aes {
@@ -87,14 +135,124 @@ func NewAccountTimeLine(aes []AccountEvent) (AccountTimeLine, error) {
 	return atl, nil
 }
 
-func openAtDate(atl AccountTimeLine, posting Posting) bool {
-	at := atl[posting.Account.Name]
-	open := false
-	for _, ae := range at {
-		if ae.Date.After(posting.Transaction.Date) {
+// allows returns true if postings in ccy are allowed by the (open) AccountEvent
+func (ae AccountEvent) allows(ccy Ccy) bool {
+	if len(ae.Ccys) == 0 {
+		return true
+	}
+	for _, c := range ae.Ccys {
+		if c == ccy {
+			return true
+		}
+	}
+	return false
+}
+
+// openEvent returns the open AccountEvent in effect for account on date,
+// or false if the account is not open then.
+// As in beancount, an account can still be used on the date it is closed.
+func openEvent(atl AccountTimeLine, account AccountName, date time.Time) (AccountEvent, bool) {
+	events := atl[account]
+	last := -1 // the last event on or before date
+	for i := range events {
+		if events[i].Date.After(date) || (!events[i].Open && events[i].Date.Equal(date)) {
 			break
 		}
-		open = ae.Open
+		last = i
+	}
+	if last < 0 {
+		return AccountEvent{}, false
+	}
+	return events[last], events[last].Open
+}
+
+// checkCurrencies checks that the units of every Posting are in
+// a currency allowed by the open directive of its account
+func checkCurrencies(transactions []Transaction, atl AccountTimeLine) error {
+	var errs []error
+	for _, tx := range transactions {
+		for _, p := range tx.Postings {
+			if p.Amount == nil {
+				continue
+			}
+			open, ok := openEvent(atl, p.Account.Name, tx.Date)
+			if !ok || open.allows(p.Amount.Ccy) {
+				continue
+			}
+			errs = append(errs, errorAt(tx.Pos, "currency %s not allowed in %s (opened at %v for %v)",
+				p.Amount.Ccy, p.Account.Name, open.Pos, open.Ccys))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func openAtDate(atl AccountTimeLine, posting Posting) bool {
+	_, ok := openEvent(atl, posting.Account.Name, posting.Transaction.Date)
+	return ok
+}
+
+// isSubAccount returns true if name is parent or any account below it
+func isSubAccount(name AccountName, parent AccountName) bool {
+	return name == parent || strings.HasPrefix(string(name), string(parent)+":")
+}
+
+// validAccountName returns true if name has one of the roots
+// followed by at least one component, and every component
+// starts with a capital letter or number
+func validAccountName(name AccountName, roots []string) bool {
+	parts := strings.Split(string(name), ":")
+	if len(parts) < 2 {
+		return false
+	}
+	validRoot := false
+	for _, root := range roots {
+		validRoot = validRoot || parts[0] == root
+	}
+	if !validRoot {
+		return false
+	}
+	for _, part := range parts[1:] {
+		if part == "" {
+			return false
+		}
+		r, _ := utf8.DecodeRuneInString(part)
+		if !unicode.IsUpper(r) && !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkAccountNames checks every account used in the Ledger
+// against the root names from the Options
+func checkAccountNames(l *Ledger) error {
+	roots := l.Options.RootNames()
+	var errs []error
+	check := func(acc Account, pos Pos) {
+		if !validAccountName(acc.Name, roots) {
+			errs = append(errs, errorAt(pos, "invalid account name %s", acc.Name))
+		}
+	}
+	for _, ae := range l.AccountEvents {
+		check(ae.Account, ae.Pos)
+	}
+	for _, tx := range l.Transactions {
+		for _, p := range tx.Postings {
+			check(p.Account, tx.Pos)
+		}
+	}
+	for _, b := range l.Balances {
+		check(b.Account, b.Pos)
+	}
+	for _, p := range l.Pads {
+		check(p.PadTo, p.Pos)
+		check(p.PadFrom, p.Pos)
+	}
+	for _, n := range l.Notes {
+		check(n.Account, n.Pos)
+	}
+	for _, d := range l.Documents {
+		check(d.Account, d.Pos)
 	}
-	return open
+	return errors.Join(errs...)
 }
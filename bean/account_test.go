@@ -12,36 +12,91 @@ func Test_AccountEvent_String(t *testing.T) {
 		Date:    time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC),
 		Open:    true,
 		Account: Account{AccountName("Assets:Bank")},
-		Ccy:     Ccy("GBP"),
+		Ccys:    []Ccy{"GBP", "USD"},
 	}
 	got := ae.String()
-	want := "2022-01-01 open Assets:Bank GBP\n"
+	want := "2022-01-01 open Assets:Bank GBP,USD\n"
 	if diff := cmp.Diff(want, got); diff != "" {
 		t.Error(diff)
 	}
 }
 
 func TestNewAccountEvent(t *testing.T) {
-	// extra account currencies should be ignored
+	// comma-separated currencies should all be kept
 	acc := "Assets:Bank"
-	ccy := "GBP"
-	directive := Directive{[]Line{
+	directive := Directive{Lines: []Line{
 		{Tokens: []Token{
 			{LineNum: 1, Text: "2023-01-01"},
 			{LineNum: 1, Text: "open"},
 			{LineNum: 1, Text: acc},
-			{LineNum: 1, Text: ccy},
-			{LineNum: 1, Text: ccy},
+			{LineNum: 1, Text: "GBP"},
+			{LineNum: 1, Kind: TokenComma, Text: ","},
+			{LineNum: 1, Text: "USD"},
 		}},
 	}}
 	want := AccountEvent{
 		Date:    time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC),
 		Open:    true,
 		Account: Account{AccountName(acc)},
-		Ccy:     Ccy(ccy),
+		Ccys:    []Ccy{"GBP", "USD"},
+		Pos:     Pos{Line: 1},
+	}
+	got, err := newAccountEvent(directive)
+	if err != nil {
+		t.Fatal(err)
 	}
-	got, _ := newAccountEvent(directive)
 	if diff := cmp.Diff(want, got); diff != "" {
 		t.Error(diff)
 	}
+
+	// currencies without commas, trailing commas, and currencies on close should error
+	for _, tokens := range [][]Token{
+		tokensOf("2023-01-01", "open", acc, "GBP", "USD"),
+		tokensOf("2023-01-01", "open", acc, "GBP", ","),
+		tokensOf("2023-01-01", "close", acc, "GBP"),
+	} {
+		if _, err := newAccountEvent(Directive{Lines: []Line{{Tokens: tokens}}}); err == nil {
+			t.Errorf("should error: %v", tokens)
+		}
+	}
+}
+
+func TestNewAccountEvent_Booking(t *testing.T) {
+	// quoted booking method should be parsed
+	directive := Directive{Lines: []Line{
+		{Tokens: []Token{
+			{LineNum: 1, Text: "2023-01-01"},
+			{LineNum: 1, Text: "open"},
+			{LineNum: 1, Text: "Assets:Invest"},
+			{LineNum: 1, Text: "GOO"},
+			{LineNum: 1, Kind: TokenString, Text: "FIFO"},
+		}},
+	}}
+	got, _ := newAccountEvent(directive)
+	if got.Booking != BookingFIFO || !cmp.Equal(got.Ccys, []Ccy{"GOO"}) {
+		t.Errorf("want GOO FIFO, got %v", got)
+	}
+
+	// invalid booking method should error
+	directive.Lines[0].Tokens[4].Text = "RANDOM"
+	_, err := newAccountEvent(directive)
+	if err == nil {
+		t.Error("invalid booking method should error")
+	}
+}
+
+func Test_validAccountName(t *testing.T) {
+	roots := defaultOptions().RootNames()
+	for name, want := range map[AccountName]bool{
+		"Assets:Bank":        true,
+		"Expenses:2023:Food": true,
+		"Assets":             false,
+		"Assets:bank":        false,
+		"Assets::Bank":       false,
+		"Actifs:Banque":      false,
+	} {
+		if got := validAccountName(name, roots); got != want {
+			t.Errorf("%s: want %v, got %v", name, want, got)
+		}
+	}
 }
@@ -1,6 +1,7 @@
 package bean
 
 import (
+	"encoding/json"
 	"fmt"
 
 	"github.com/cockroachdb/apd/v3"
@@ -74,10 +75,48 @@ func (a Amount) Neg() Amount {
 	return a
 }
 
+// Mul returns the Amount multiplied by num
+func (a Amount) Mul(num apd.Decimal) Amount {
+	res := apd.Decimal{}
+	apdCtx.Mul(&res, &a.Number, &num)
+	a.Number = res
+	return a
+}
+
+// Quo returns the Amount divided by num
+func (a Amount) Quo(num apd.Decimal) (Amount, error) {
+	res := apd.Decimal{}
+	_, err := apdQuoCtx.Quo(&res, &a.Number, &num)
+	if err != nil {
+		return Amount{}, fmt.Errorf("in Quo: %w", err)
+	}
+	res.Reduce(&res)
+	a.Number = res
+	return a, nil
+}
+
+// Abs returns the absolute value of the Amount
+func (a Amount) Abs() Amount {
+	abs := apd.Decimal{}
+	apdCtx.Abs(&abs, &a.Number)
+	a.Number = abs
+	return a
+}
+
 func (a Amount) String() string {
 	return fmt.Sprintf("%s %s", a.Number.Text('f'), a.Ccy)
 }
 
+// MarshalJSON renders the Number as a string, so no precision is lost.
+// It has a value receiver so that Amounts in maps (which aren't addressable)
+// are rendered the same.
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Number string `json:"number"`
+		Ccy    Ccy    `json:"currency"`
+	}{a.Number.Text('f'), a.Ccy})
+}
+
 // CcyAmount is a map of Ccy -> number
 type CcyAmount = map[Ccy]Amount
 
@@ -94,6 +133,16 @@ func NewCcyAmount(bals map[string]string) (CcyAmount, error) {
 	return res, nil
 }
 
+// addToCcyAmount adds amt to the matching Ccy in ca
+func addToCcyAmount(ca CcyAmount, amt Amount) {
+	cur, ok := ca[amt.Ccy]
+	if ok {
+		ca[amt.Ccy] = cur.MustAdd(amt)
+	} else {
+		ca[amt.Ccy] = amt
+	}
+}
+
 // MustNewCcyAmount converts a regular string map to a CcyAmount
 func MustNewCcyAmount(bals map[string]string) CcyAmount {
 	res := make(CcyAmount, len(bals))
@@ -0,0 +1,65 @@
+package bean
+
+import (
+	"errors"
+	"log"
+	"sort"
+	"time"
+)
+
+// checkBalances compares every Balance assertion against the running balance
+// of its account at the _start_ of the asserted date.
+// Postings to sub-accounts are included, as in beancount.
+// Differences within the tolerance of the Balance are allowed.
+// Postings must already be sorted. All failing assertions are returned.
+func checkBalances(balances []Balance, postings []Posting, opts Options) error {
+	sorted := make([]Balance, len(balances))
+	copy(sorted, balances)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Date.Before(sorted[j].Date)
+	})
+
+	running := make(AccBal, 20)
+	next := 0
+	var errs []error
+	for _, b := range sorted {
+		for ; next < len(postings) && postings[next].Transaction.Date.Before(b.Date); next++ {
+			addPosting(running, postings[next])
+		}
+		actual := sumSubAccounts(running, b.Account.Name, b.Amount.Ccy)
+		if debug {
+			log.Println("checkBalance", b.Account.Name, b.Amount, actual)
+		}
+		diff := actual.MustAdd(b.Amount.Neg())
+		if !within(diff, balanceTolerance(b, opts)) {
+			errs = append(errs, errorAt(
+				b.Pos, "balance failed for %s on %s: expected %v, actual %v, difference %v",
+				b.Account.Name, b.Date.Format(time.DateOnly), b.Amount, actual, diff,
+			))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// addPosting adds the Amount of the Posting to the running balances
+func addPosting(bals AccBal, p Posting) {
+	acc := p.Account.Name
+	if bals[acc] == nil {
+		bals[acc] = make(CcyAmount, 3)
+	}
+	addToCcyAmount(bals[acc], *p.Amount)
+}
+
+// sumSubAccounts returns the total in ccy of account and all its sub-accounts
+func sumSubAccounts(bals AccBal, account AccountName, ccy Ccy) Amount {
+	total := Amount{Ccy: ccy}
+	for acc, ccyAmount := range bals {
+		if !isSubAccount(acc, account) {
+			continue
+		}
+		if amt, ok := ccyAmount[ccy]; ok {
+			total = total.MustAdd(amt)
+		}
+	}
+	return total
+}
@@ -0,0 +1,62 @@
+package bean
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_checkBalances(t *testing.T) {
+	tx := Transaction{Date: time.Date(2023, time.January, 2, 0, 0, 0, 0, time.UTC)}
+	val1 := MustNewAmount("100", "GBP")
+	val2 := MustNewAmount("50.00", "GBP")
+	postings := []Posting{
+		{Account: Account{"Assets:Bank"}, Amount: &val1, Transaction: &tx},
+		{Account: Account{"Assets:Bank:Savings"}, Amount: &val2, Transaction: &tx},
+	}
+
+	// sub-accounts should be included
+	balances := []Balance{{
+		Date:    time.Date(2023, time.January, 3, 0, 0, 0, 0, time.UTC),
+		Account: Account{"Assets:Bank"},
+		Amount:  MustNewAmount("150", "GBP"),
+	}}
+	if err := checkBalances(balances, postings, defaultOptions()); err != nil {
+		t.Error(err)
+	}
+
+	// postings on the asserted date should not be included
+	balances = []Balance{{
+		Date:    time.Date(2023, time.January, 2, 0, 0, 0, 0, time.UTC),
+		Account: Account{"Assets:Bank"},
+		Amount:  MustNewAmount("0", "GBP"),
+	}}
+	if err := checkBalances(balances, postings, defaultOptions()); err != nil {
+		t.Error(err)
+	}
+
+	// every failing assertion should be reported
+	balances = []Balance{
+		{
+			Date:    time.Date(2023, time.January, 3, 0, 0, 0, 0, time.UTC),
+			Account: Account{"Assets:Bank"},
+			Amount:  MustNewAmount("140", "GBP"),
+			Pos:     Pos{Line: 7},
+		},
+		{
+			Date:    time.Date(2023, time.January, 3, 0, 0, 0, 0, time.UTC),
+			Account: Account{"Assets:Bank:Savings"},
+			Amount:  MustNewAmount("10", "USD"),
+			Pos:     Pos{Line: 8},
+		},
+	}
+	err := checkBalances(balances, postings, defaultOptions())
+	if err == nil {
+		t.Fatal("failing balances should error")
+	}
+	got := err.Error()
+	want := "line 7: balance failed for Assets:Bank on 2023-01-03: expected 140 GBP, actual 150.00 GBP, difference 10.00 GBP\n" +
+		"line 8: balance failed for Assets:Bank:Savings on 2023-01-03: expected 10 USD, actual 0 USD, difference -10 USD"
+	if got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
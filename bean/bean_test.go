@@ -67,3 +67,513 @@ func Test_GetBalances(t *testing.T) {
 		t.Error("must fail with invalid file")
 	}
 }
+
+func Test_Load_Balances(t *testing.T) {
+	// correct balance assertion should pass
+	text := `
+2023-01-01 open Assets:Bank GBP
+2023-01-01 open Income:Job GBP
+
+2023-02-01 * "Salary"
+  Assets:Bank                          1000 GBP
+  Income:Job
+
+2023-02-02 balance Assets:Bank         1000 GBP
+`
+	rc := io.NopCloser(strings.NewReader(text))
+	_, err := bean.NewLedger(false).Load(rc)
+	if err != nil {
+		t.Error(err)
+	}
+
+	// wrong balance assertion should error
+	text = strings.Replace(text, "balance Assets:Bank         1000 GBP", "balance Assets:Bank 999 GBP", 1)
+	rc = io.NopCloser(strings.NewReader(text))
+	_, err = bean.NewLedger(false).Load(rc)
+	if err == nil {
+		t.Error("must fail with wrong balance assertion")
+	}
+}
+
+func Test_Load_CostAndPrice(t *testing.T) {
+	// trades with cost and price annotations should balance
+	text := `
+2023-01-01 open Assets:Bank
+2023-01-01 open Assets:Invest
+
+2023-02-01 * "Buy"
+  Assets:Invest                   10 GOO {150 USD, "lot1"}
+  Assets:Bank
+
+2023-02-02 * "Exchange"
+  Assets:Bank                  -100 USD @ 0.9 EUR
+  Assets:Bank                    90 EUR
+`
+	rc := io.NopCloser(strings.NewReader(text))
+	l, err := bean.NewLedger(false).Load(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := l.GetBalances(time.Date(2023, time.March, 1, 0, 0, 0, 0, time.UTC))
+	want := bean.AccBal{
+		"Assets:Bank":   bean.MustNewCcyAmount(map[string]string{"USD": "-1600", "EUR": "90"}),
+		"Assets:Invest": bean.MustNewCcyAmount(map[string]string{"GOO": "10"}),
+	}
+	comparer := cmp.Comparer(func(x, y bean.Amount) bool {
+		return x.Eq(y)
+	})
+	if diff := cmp.Diff(want, got, comparer); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func Test_GetInventories(t *testing.T) {
+	// lots should be reduced using the account booking method
+	text := `
+2023-01-01 open Assets:Bank
+2023-01-01 open Assets:Invest GOO "LIFO"
+
+2023-02-01 * "Buy"
+  Assets:Invest                   10 GOO {100 USD}
+  Assets:Bank
+
+2023-02-02 * "Buy"
+  Assets:Invest                   10 GOO {120 USD}
+  Assets:Bank
+
+2023-02-03 * "Sell"
+  Assets:Invest                  -15 GOO {}
+  Assets:Bank
+`
+	rc := io.NopCloser(strings.NewReader(text))
+	l, err := bean.NewLedger(false).Load(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	invs, _ := l.GetInventories(time.Date(2023, time.March, 1, 0, 0, 0, 0, time.UTC))
+	got := invs["Assets:Invest"].String()
+	want := "  5 GOO {100 USD, 2023-02-01}\n"
+	if got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+	bals, _ := l.GetBalances(time.Date(2023, time.March, 1, 0, 0, 0, 0, time.UTC))
+	if bank := bals["Assets:Bank"]["USD"]; !bank.Eq(bean.MustNewAmount("-500", "USD")) {
+		t.Errorf("sale should be at cost of matched lots, got %v", bank)
+	}
+}
+
+func Test_RealizedGains(t *testing.T) {
+	text := `
+2023-01-01 open Assets:Bank
+2023-01-01 open Assets:Invest GOO "FIFO"
+2023-01-01 open Income:Invest:Gains
+
+2023-02-01 * "Buy"
+  Assets:Invest                   10 GOO {100 USD}
+  Assets:Bank
+
+2024-03-01 * "Sell"
+  Assets:Invest                   -4 GOO {} @ 150 USD
+  Assets:Bank                    600 USD
+  Income:Invest:Gains
+`
+	rc := io.NopCloser(strings.NewReader(text))
+	l, err := bean.NewLedger(false).Load(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := l.RealizedGains()
+	if len(got) != 1 || got[0].Year != 2024 || len(got[0].Disposals) != 1 {
+		t.Fatalf("want one disposal in 2024, got %v", got)
+	}
+	if gain := got[0].Gain["USD"]; !gain.Eq(bean.MustNewAmount("200", "USD")) {
+		t.Errorf("want gain of 200 USD, got %v", gain)
+	}
+
+	// wrong gains leg should error
+	text = strings.Replace(text, "  Income:Invest:Gains\n", "  Income:Invest:Gains -100 USD\n  Assets:Bank -100 USD\n", 1)
+	rc = io.NopCloser(strings.NewReader(text))
+	_, err = bean.NewLedger(false).Load(rc)
+	if err == nil {
+		t.Error("must fail with wrong gains leg")
+	}
+}
+
+func Test_LoadFile(t *testing.T) {
+	// included files should be loaded and tagged with their path
+	l, err := bean.NewLedger(false).LoadFile("./testdata/include/main.bean")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l.Transactions) != 3 {
+		t.Fatalf("want 3 transactions, got %d", len(l.Transactions))
+	}
+	want := bean.Pos{File: "testdata/include/2023/02.bean", Line: 5}
+	if got := l.Transactions[2].Pos; got != want {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
+
+func Test_Load_Options(t *testing.T) {
+	// root account names should come from options
+	text := `
+option "name_assets" "Actifs"
+option "not_an_option" "foo"
+
+2023-01-01 open Actifs:Banque
+`
+	rc := io.NopCloser(strings.NewReader(text))
+	l, err := bean.NewLedger(false).Load(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l.Warnings) != 1 {
+		t.Errorf("want 1 warning, got %v", l.Warnings)
+	}
+
+	text = strings.Replace(text, "Actifs:Banque", "Assets:Bank", 1)
+	rc = io.NopCloser(strings.NewReader(text))
+	_, err = bean.NewLedger(false).Load(rc)
+	if err == nil {
+		t.Error("must fail with invalid root account name")
+	}
+}
+
+func Test_Load_Meta(t *testing.T) {
+	text := `
+2023-01-01 open Assets:Invest GOO "FIFO"
+  portfolio: "all"
+`
+	rc := io.NopCloser(strings.NewReader(text))
+	l, err := bean.NewLedger(false).Load(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := l.AccountEvents[0].Meta["portfolio"]; got.Kind != bean.MetaString || got.Text != "all" {
+		t.Errorf("want portfolio: \"all\", got %v", got)
+	}
+}
+
+func Test_Load_Tags(t *testing.T) {
+	text := `
+2023-01-01 open Assets:Bank
+2023-01-01 open Expenses:Food
+
+pushtag #trip
+pushmeta location: "Paris"
+
+2023-01-02 * "Cafe" "Lunch" #food ^receipt
+  Assets:Bank  -10 GBP
+  Expenses:Food
+
+popmeta location:
+poptag #trip
+
+2023-01-03 * "Dinner"
+  Assets:Bank  -20 GBP
+  Expenses:Food
+`
+	rc := io.NopCloser(strings.NewReader(text))
+	l, err := bean.NewLedger(false).Load(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, second := l.Transactions[0], l.Transactions[1]
+	if !first.Tags.Has("trip") || !first.Tags.Has("food") || !first.Links.Has("receipt") {
+		t.Errorf("wrong tags/links: %v %v", first.Tags, first.Links)
+	}
+	if first.Meta["location"].Text != "Paris" {
+		t.Errorf("pushed meta missing: %v", first.Meta)
+	}
+	if second.Tags != nil || second.Meta != nil {
+		t.Errorf("popped tags/meta should not apply: %v %v", second.Tags, second.Meta)
+	}
+
+	// unbalanced pushtag should error
+	rc = io.NopCloser(strings.NewReader("pushtag #trip\n"))
+	if _, err := bean.NewLedger(false).Load(rc); err == nil {
+		t.Error("unbalanced pushtag should error")
+	}
+}
+
+func Test_LoadAll(t *testing.T) {
+	// every bad entry should be reported, and the rest still loaded
+	text := `
+option "unknown" "x"
+2023-01-01 open Assets:Bank
+2023-01-01 open Expenses:Food
+
+2023-01-02 balance Assets:Bank 10 BAD CCY
+
+2023-01-03 price GOO xyz GBP
+
+2023-01-04 * "Unbalanced"
+  Assets:Bank  -10 GBP
+  Expenses:Food  5 GBP
+
+2023-01-05 * "Fine"
+  Assets:Bank  -20 GBP
+  Expenses:Food
+`
+	rc := io.NopCloser(strings.NewReader(text))
+	res := bean.NewLedger(false).LoadAll(rc)
+	if !res.HasErrors() {
+		t.Fatal("should have errors")
+	}
+	var lines []int
+	for _, e := range res.Errors {
+		lines = append(lines, e.Pos.Line)
+	}
+	if diff := cmp.Diff([]int{2, 6, 8, 10}, lines); diff != "" {
+		t.Error(diff)
+	}
+	if e := res.Errors[0]; e.Severity != bean.SeverityWarning {
+		t.Errorf("unknown option should be a warning: %v", e)
+	}
+	if e := res.Errors[2]; e.Column != 1 || e.Directive != "2023-01-03 price GOO xyz GBP" {
+		t.Errorf("wrong column/directive: %d %q", e.Column, e.Directive)
+	}
+	if len(res.Ledger.Transactions) != 1 || res.Ledger.Transactions[0].Narration != "Fine" {
+		t.Errorf("valid transaction should be kept: %v", res.Ledger.Transactions)
+	}
+
+	// Load should return the same errors joined
+	rc = io.NopCloser(strings.NewReader(text))
+	if _, err := bean.NewLedger(false).Load(rc); err == nil {
+		t.Error("Load should error")
+	}
+}
+
+func Test_Load_Currencies(t *testing.T) {
+	// postings in currencies not allowed by the open directive should error
+	text := `
+2023-01-01 open Assets:Bank GBP,USD
+2023-01-01 open Expenses:Food
+
+2023-01-02 * "Lunch"
+  Assets:Bank  -10 USD
+  Expenses:Food
+
+2023-01-03 * "Dinner"
+  Assets:Bank  -10 EUR
+  Expenses:Food
+`
+	rc := io.NopCloser(strings.NewReader(text))
+	res := bean.NewLedger(false).LoadAll(rc)
+	if len(res.Errors) != 1 {
+		t.Fatalf("want 1 error, got %v", res.Errors)
+	}
+	got := res.Errors[0].Error()
+	if !strings.Contains(got, "currency EUR not allowed in Assets:Bank") || !strings.Contains(got, "opened at line 2") {
+		t.Errorf("wrong error: %s", got)
+	}
+}
+
+func Test_Load_Lifecycle(t *testing.T) {
+	// each line with a lifecycle problem should be reported once
+	text := `
+2023-01-01 open Assets:Bank
+2023-01-01 open Expenses:Food
+2023-01-02 open Assets:Bank
+2023-01-03 close Income:Job
+
+2023-01-04 * "Lunch"
+  Assets:Bank  -10 GBP
+  Expenses:Food
+
+2023-01-05 close Assets:Bank
+2023-01-06 close Assets:Bank
+2023-01-07 balance Assets:Bank  -10 GBP
+2023-01-08 note Assets:Cash "never opened"
+2023-01-09 pad Assets:Bank Expenses:Food
+`
+	rc := io.NopCloser(strings.NewReader(text))
+	res := bean.NewLedger(false).LoadAll(rc)
+	var got []string
+	for _, e := range res.Errors {
+		got = append(got, e.Error())
+	}
+	want := []string{
+		"line 4:1: account Assets:Bank opened twice (already opened at line 2)",
+		"line 5:1: account Income:Job closed before it was opened",
+		"line 11:1: account Assets:Bank closed with non-zero balance -10 GBP",
+		"line 12:1: account Assets:Bank closed twice (already closed at line 11)",
+		"line 13:1: balance uses account Assets:Bank which is closed on 2023-01-07",
+		"line 14:1: note uses account Assets:Cash which is never opened on 2023-01-08",
+		"line 15:1: unused pad for Assets:Bank on 2023-01-09",
+		"line 15:1: pad uses account Assets:Bank which is closed on 2023-01-09",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func Test_Load_AutoOpen(t *testing.T) {
+	// accounts should be opened on first use with the plugin
+	text := `
+plugin "beancount.plugins.auto_accounts"
+
+2023-01-04 * "Lunch"
+  Assets:Bank  -10 GBP
+  Expenses:Food
+
+2023-01-05 balance Assets:Bank  -10 GBP
+`
+	rc := io.NopCloser(strings.NewReader(text))
+	ledger, err := bean.NewLedger(false).Load(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bals, err := ledger.GetBalances(time.Date(2023, 1, 5, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := bals["Expenses:Food"]["GBP"]; got.Number.String() != "10" {
+		t.Errorf("wrong balance: %v", got)
+	}
+	if len(ledger.AccountEvents) != 2 {
+		t.Errorf("want 2 auto opens, got %v", ledger.AccountEvents)
+	}
+
+	// and not without it
+	rc = io.NopCloser(strings.NewReader(strings.Replace(text, "plugin", ";", 1)))
+	if _, err := bean.NewLedger(false).Load(rc); err == nil {
+		t.Error("should error without auto open")
+	}
+}
+
+func Test_Load_PriceMap(t *testing.T) {
+	// prices should come from directives and postings
+	text := `
+2023-01-01 open Assets:Bank
+2023-01-01 open Assets:Invest
+2023-01-01 price GBP 1.25 USD
+
+2023-01-02 * "Buy"
+  Assets:Invest  10 GOO {50 GBP}
+  Assets:Bank
+`
+	rc := io.NopCloser(strings.NewReader(text))
+	ledger, err := bean.NewLedger(false).Load(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	date := time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC)
+	if got, ok := ledger.PriceMap.Price("GOO", "USD", date); !ok || got.String() != "62.50 USD" {
+		t.Errorf("wrong price: %v", got)
+	}
+}
+
+func Test_GetBalancesIn(t *testing.T) {
+	// balances should convert at cost or market, with unpriced amounts separate
+	text := `
+2023-01-01 open Assets:Bank
+2023-01-01 open Assets:Invest
+2023-01-01 open Assets:Other
+
+2023-01-02 * "Buy"
+  Assets:Invest  10 GOO {50 GBP}
+  Assets:Bank
+
+2023-01-03 * "Gift"
+  Assets:Other  5 XYZ
+  Assets:Bank  -5 XYZ
+
+2023-01-04 price GOO 60 GBP
+2023-01-04 price GBP 1.25 USD
+`
+	rc := io.NopCloser(strings.NewReader(text))
+	ledger, err := bean.NewLedger(false).Load(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	date := time.Date(2023, 1, 5, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		ccy       bean.Ccy
+		valuation bean.Valuation
+		want      string
+	}{
+		{"GBP", bean.ValueUnits, "10 GOO"},
+		{"GBP", bean.ValueCost, "500 GBP"},
+		{"GBP", bean.ValueMarket, "600 GBP"},
+		{"USD", bean.ValueMarket, "750.00 USD"},
+	}
+	for _, tt := range tests {
+		bals, err := ledger.GetBalancesIn(date, tt.ccy, tt.valuation)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, amt := range bals.Balances["Assets:Invest"] {
+			got = append(got, amt.String())
+		}
+		if diff := cmp.Diff([]string{tt.want}, got); diff != "" {
+			t.Errorf("%s %s: %s", tt.ccy, tt.valuation, diff)
+		}
+		if tt.valuation == bean.ValueUnits {
+			continue
+		}
+		if got := bals.Unpriced["Assets:Other"]["XYZ"]; got.String() != "5 XYZ" {
+			t.Errorf("%s %s: XYZ should be unpriced: %v", tt.ccy, tt.valuation, bals.Unpriced)
+		}
+	}
+	if _, err := bean.NewValuation("bad"); err == nil {
+		t.Error("should error on invalid valuation")
+	}
+}
+
+func Test_Load_Tolerance(t *testing.T) {
+	// rounding within the default inferred tolerance should balance
+	text := `
+2023-01-01 open Assets:GBP
+2023-01-01 open Assets:EUR
+
+2023-01-02 * "FX"
+  Assets:GBP  -33.33 GBP @ 1.17 EUR
+  Assets:EUR   39.00 EUR
+
+2023-01-03 * "Too far out"
+  Assets:GBP  -33.33 GBP @ 1.17 EUR
+  Assets:EUR   39.10 EUR
+
+2023-01-04 balance Assets:EUR  39.01 EUR
+2023-01-04 balance Assets:GBP  -33.3 ~ 0.05 GBP
+2023-01-04 balance Assets:GBP  -33.30 GBP
+`
+	rc := io.NopCloser(strings.NewReader(text))
+	res := bean.NewLedger(false).LoadAll(rc)
+	var got []string
+	for _, e := range res.Errors {
+		got = append(got, e.Error())
+	}
+	if len(got) != 2 {
+		t.Fatalf("want 2 errors, got %v", got)
+	}
+	if !strings.HasPrefix(got[0], "line 9:1: transaction does not balance: residual 0.1039 EUR exceeds tolerance 0.00585 EUR") {
+		t.Errorf("wrong error: %s", got[0])
+	}
+	if !strings.HasPrefix(got[1], "line 15:1: balance failed for Assets:GBP") {
+		t.Errorf("wrong error: %s", got[1])
+	}
+}
+
+func Test_Load_ToleranceMultiplier(t *testing.T) {
+	// a smaller multiplier should make the same rounding fail
+	text := `
+option "inferred_tolerance_multiplier" "0.3"
+2023-01-01 open Assets:GBP
+2023-01-01 open Assets:EUR
+
+2023-01-02 * "FX"
+  Assets:GBP  -33.33 GBP @ 1.17 EUR
+  Assets:EUR   39.00 EUR
+`
+	rc := io.NopCloser(strings.NewReader(text))
+	res := bean.NewLedger(false).LoadAll(rc)
+	if len(res.Errors) != 1 {
+		t.Fatalf("want 1 error, got %v", res.Errors)
+	}
+	if got := res.Errors[0].Error(); !strings.HasPrefix(got, "line 6:1: transaction does not balance: residual 0.0039 EUR exceeds tolerance 0.00351 EUR") {
+		t.Errorf("wrong error: %s", got)
+	}
+}
@@ -0,0 +1,79 @@
+package bean
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// benchLedger generates a ledger with n days of transactions
+func benchLedger(n int) string {
+	var b strings.Builder
+	b.WriteString(`option "operating_currency" "GBP"
+
+2010-01-01 open Assets:Bank GBP
+2010-01-01 open Assets:Invest GOO "FIFO"
+2010-01-01 open Expenses:Food
+2010-01-01 open Income:Job
+  employer: "Acme"
+
+`)
+	date := time.Date(2010, time.January, 2, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < n; i++ {
+		d := date.AddDate(0, 0, i).Format(time.DateOnly)
+		fmt.Fprintf(&b, `; day %d
+%s * "Shop \"Corner\"" "Lunch" #food ^receipt-%d
+  receipt: "scan.pdf"
+  Assets:Bank                          -12.50 GBP
+  Expenses:Food
+
+%s * "Salary"
+  Assets:Bank                          22.50 GBP
+  Income:Job
+
+`, i, d, i, d)
+		if i%30 == 0 {
+			fmt.Fprintf(&b, `%s * "Broker" "Buy"
+  Assets:Invest                           1 GOO {10.00 GBP, %s}
+  Assets:Bank                          -10.00 GBP @@ 10.00 GBP
+
+`, d, d)
+		}
+	}
+	return b.String()
+}
+
+func BenchmarkGetTokens(b *testing.B) {
+	text := benchLedger(5000)
+	b.SetBytes(int64(len(text)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = getTokens(io.NopCloser(strings.NewReader(text)))
+	}
+}
+
+func BenchmarkParse(b *testing.B) {
+	text := benchLedger(5000)
+	b.SetBytes(int64(len(text)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := NewLedger(false).parse(io.NopCloser(strings.NewReader(text)))
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkLoad(b *testing.B) {
+	text := benchLedger(5000)
+	b.SetBytes(int64(len(text)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		res := NewLedger(false).LoadAll(io.NopCloser(strings.NewReader(text)))
+		if res.HasErrors() {
+			b.Fatal(res.Err())
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package bean
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+)
+
+// Booking is the method used to match reductions against the lots in an Inventory
+type Booking string
+
+// Booking methods supported by beancount
+const (
+	BookingStrict  Booking = "STRICT"
+	BookingFIFO    Booking = "FIFO"
+	BookingLIFO    Booking = "LIFO"
+	BookingHIFO    Booking = "HIFO"
+	BookingAverage Booking = "AVERAGE"
+	BookingNone    Booking = "NONE"
+)
+
+// defaultBooking is used for accounts opened without a booking method
+const defaultBooking = BookingStrict
+
+// newBooking checks that str is a valid Booking method
+func newBooking(str string) (Booking, error) {
+	switch b := Booking(str); b {
+	case BookingStrict, BookingFIFO, BookingLIFO, BookingHIFO, BookingAverage, BookingNone:
+		return b, nil
+	}
+	return "", fmt.Errorf("invalid booking method: %s", str)
+}
+
+// accountBooking returns the Booking method from the account's open directive,
+// or def if it doesn't have one
+func accountBooking(atl AccountTimeLine, account AccountName, def Booking) Booking {
+	booking := def
+	for _, ae := range atl[account] {
+		if ae.Open && ae.Booking != "" {
+			booking = ae.Booking
+		}
+	}
+	return booking
+}
+
+// bookTransactions goes through the Transactions in date order and
+// matches every reduction of units held at cost against the lots in the
+// account's Inventory, using the account's Booking method.
+// Reducing Postings are split into one Posting per matched lot,
+// each with the full Cost of that lot.
+// Reductions with a price are returned as Disposals.
+// def is the Booking method for accounts that don't have one.
+func bookTransactions(transactions []Transaction, atl AccountTimeLine, def Booking) ([]Transaction, []Disposal, error) {
+	order := make([]int, len(transactions))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return transactions[order[i]].Date.Before(transactions[order[j]].Date)
+	})
+
+	invs := make(AccInv, 20)
+	var disposals []Disposal
+	var errs []error
+	for _, i := range order {
+		tx, txDisposals, err := bookTransaction(transactions[i], invs, atl, def)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		transactions[i] = tx
+		for _, d := range txDisposals {
+			d.txIndex = i
+			disposals = append(disposals, d)
+		}
+	}
+	return transactions, disposals, errors.Join(errs...)
+}
+
+// hasCost returns true if any Posting of tx has a Cost
+func hasCost(tx Transaction) bool {
+	for _, p := range tx.Postings {
+		if p.Cost != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// bookTransaction books the Postings of a single Transaction
+// and applies them to the inventories.
+// Only units held at cost can be reduced, so the inventories
+// only hold lots, and Transactions without a Cost are unchanged.
+// The Postings are booked against copies of the inventories, which
+// replace them only if every Posting is booked, so a Transaction
+// that fails leaves invs unchanged.
+func bookTransaction(tx Transaction, invs AccInv, atl AccountTimeLine, def Booking) (Transaction, []Disposal, error) {
+	if !hasCost(tx) {
+		return tx, nil, nil
+	}
+	postings := make([]Posting, 0, len(tx.Postings))
+	var disposals []Disposal
+	booked := make(AccInv, 2)
+	for _, p := range tx.Postings {
+		if p.Amount == nil || p.Cost == nil {
+			postings = append(postings, p)
+			continue
+		}
+		acc := p.Account.Name
+		inv := booked[acc]
+		if inv == nil {
+			inv = invs[acc].clone()
+			booked[acc] = inv
+		}
+
+		booking := accountBooking(atl, acc, def)
+		if booking == BookingNone || !inv.isReduction(*p.Amount) {
+			lot, err := newLot(*p.Amount, *p.Cost, tx.Date)
+			if err != nil {
+				return Transaction{}, nil, errorFrom(tx.Pos, err)
+			}
+			inv.Add(*p.Amount, &lot)
+			postings = append(postings, p)
+			continue
+		}
+
+		reductions, err := inv.reduce(*p.Amount, *p.Cost, booking)
+		if err != nil {
+			return Transaction{}, nil, errorFrom(tx.Pos, err)
+		}
+		if debug {
+			log.Println("bookTransaction", acc, reductions)
+		}
+		for _, r := range reductions {
+			split := p
+			units := r.Units
+			cost := r.Lot.Cost
+			split.Amount = &units
+			split.Cost = &Cost{Amount: &cost, Date: r.Lot.Date, Label: r.Lot.Label}
+			if p.Price != nil && p.PriceTotal && len(reductions) > 1 {
+				// a total price must be split between the lots
+				price, err := p.Price.Quo(p.Amount.Abs().Number)
+				if err != nil {
+					return Transaction{}, nil, errorFrom(tx.Pos, err)
+				}
+				split.Price = &price
+				split.PriceTotal = false
+			}
+			inv.Add(units, r.Lot)
+			postings = append(postings, split)
+			if d, ok := newDisposal(tx, split, r); ok {
+				disposals = append(disposals, d)
+			}
+		}
+	}
+	for acc, inv := range booked {
+		invs[acc] = inv
+	}
+	tx.Postings = postings
+	return tx, disposals, nil
+}
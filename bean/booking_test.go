@@ -0,0 +1,107 @@
+package bean
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_newBooking(t *testing.T) {
+	got, err := newBooking("FIFO")
+	if err != nil || got != BookingFIFO {
+		t.Errorf("want FIFO, got %s %v", got, err)
+	}
+	_, err = newBooking("fifo")
+	if err == nil {
+		t.Error("invalid booking should error")
+	}
+}
+
+func Test_bookTransactions(t *testing.T) {
+	acc := Account{"Assets:Invest"}
+	atl := AccountTimeLine{
+		acc.Name: {{Open: true, Account: acc, Booking: BookingFIFO}},
+	}
+	buy1 := MustNewAmount("10", "GOO")
+	buy2 := MustNewAmount("10", "GOO")
+	sell := MustNewAmount("-15", "GOO")
+	cost1 := MustNewAmount("100", "USD")
+	cost2 := MustNewAmount("120", "USD")
+	transactions := []Transaction{
+		{
+			// sale is listed first, but must be booked after the buys
+			Date:     time.Date(2023, time.March, 1, 0, 0, 0, 0, time.UTC),
+			Postings: []Posting{{Account: acc, Amount: &sell, Cost: &Cost{}}},
+		},
+		{
+			Date:     time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC),
+			Postings: []Posting{{Account: acc, Amount: &buy1, Cost: &Cost{Amount: &cost1}}},
+		},
+		{
+			Date:     time.Date(2023, time.February, 1, 0, 0, 0, 0, time.UTC),
+			Postings: []Posting{{Account: acc, Amount: &buy2, Cost: &Cost{Amount: &cost2}}},
+		},
+	}
+	got, _, err := bookTransactions(transactions, atl, BookingStrict)
+	if err != nil {
+		t.Fatal(err)
+	}
+	postings := got[0].Postings
+	if len(postings) != 2 {
+		t.Fatalf("sale should be split into 2 postings: %v", postings)
+	}
+	want := []string{
+		"Assets:Invest: -10 GOO {100 USD, 2023-01-01}",
+		"Assets:Invest: -5 GOO {120 USD, 2023-02-01}",
+	}
+	for i, p := range postings {
+		if p.String() != want[i] {
+			t.Errorf("want %s, got %s", want[i], p)
+		}
+	}
+
+	// selling from an empty inventory is an augmentation, which needs a cost
+	transactions = []Transaction{{
+		Date:     time.Date(2023, time.March, 1, 0, 0, 0, 0, time.UTC),
+		Postings: []Posting{{Account: acc, Amount: &sell, Cost: &Cost{}}},
+	}}
+	_, _, err = bookTransactions(transactions, atl, BookingStrict)
+	if err == nil {
+		t.Error("augmentation without cost should error")
+	}
+}
+
+func Test_bookTransactions_failed(t *testing.T) {
+	// the buy in the failed transaction must not be left in the inventory,
+	// so the later sale is short
+	res := NewLedger(false).LoadAll(io.NopCloser(strings.NewReader(`
+option "booking_method" "FIFO"
+2023-01-01 open Assets:Invest
+2023-01-01 open Assets:Bank
+
+2023-01-01 * "Buy"
+  Assets:Invest  5 GOO {10 GBP}
+  Assets:Bank
+
+2023-01-02 * "Buy and bad sale"
+  Assets:Invest  5 GOO {10 GBP}
+  Assets:Invest  -100 GOO {99 GBP}
+  Assets:Bank
+
+2023-01-03 * "Sale"
+  Assets:Invest  -10 GOO {10 GBP}
+  Assets:Bank  100 GBP
+`)))
+	if len(res.Errors) != 2 {
+		t.Fatalf("want 2 errors, got %v", res.Errors)
+	}
+	invs, err := res.Ledger.GetInventories(time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "  5 GOO {10 GBP, 2023-01-01}\n"
+	if got := invs["Assets:Invest"].String(); got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,82 @@
+package bean
+
+import (
+	"fmt"
+	"time"
+)
+
+// Valuation is how balances are converted to a target currency
+type Valuation string
+
+// Valuations
+const (
+	ValueUnits  Valuation = "units"  // no conversion
+	ValueCost   Valuation = "cost"   // lots at their cost, then at market value
+	ValueMarket Valuation = "market" // at the latest price on or before the date
+)
+
+// NewValuation checks that str is a valid Valuation
+func NewValuation(str string) (Valuation, error) {
+	switch v := Valuation(str); v {
+	case ValueUnits, ValueCost, ValueMarket:
+		return v, nil
+	}
+	return "", fmt.Errorf("invalid valuation: %s (must be units, cost or market)", str)
+}
+
+// ConvertedBalances are account balances converted to a single currency.
+// Unpriced has the amounts (in their own currency) that could not be converted,
+// and is not included in Balances.
+type ConvertedBalances struct {
+	Ccy       Ccy       `json:"currency"`
+	Valuation Valuation `json:"valuation"`
+	Balances  AccBal    `json:"balances"`
+	Unpriced  AccBal    `json:"unpriced"`
+}
+
+// GetBalancesIn returns the balance of all accounts at the start of date
+// converted to ccy using valuation, with prices from the PriceMap on date
+func (l *Ledger) GetBalancesIn(date time.Time, ccy Ccy, valuation Valuation) (ConvertedBalances, error) {
+	invs, err := l.GetInventories(date)
+	if err != nil {
+		res := ConvertedBalances{Ccy: ccy, Valuation: valuation, Balances: AccBal{}, Unpriced: AccBal{}}
+		return res, fmt.Errorf("in GetBalancesIn: %w", err)
+	}
+	return l.convertInventories(invs, ccy, valuation, date), nil
+}
+
+// convertInventories converts invs to ccy using valuation,
+// with prices from the PriceMap on date
+func (l *Ledger) convertInventories(invs AccInv, ccy Ccy, valuation Valuation, date time.Time) ConvertedBalances {
+	res := ConvertedBalances{Ccy: ccy, Valuation: valuation, Balances: AccBal{}, Unpriced: AccBal{}}
+	pm := l.PriceMap
+	if pm == nil {
+		pm = NewPriceMap(nil, nil)
+	}
+	for acc, inv := range invs {
+		for _, p := range inv.Positions {
+			amt := p.Units
+			if valuation == ValueCost && p.Lot != nil {
+				amt = p.Lot.Cost.Mul(p.Units.Number)
+			}
+			if valuation != ValueUnits {
+				converted, ok := pm.Convert(amt, ccy, date)
+				if !ok {
+					addAccBal(res.Unpriced, acc, amt)
+					continue
+				}
+				amt = converted
+			}
+			addAccBal(res.Balances, acc, amt)
+		}
+	}
+	return res
+}
+
+// addAccBal adds amt to the balance of acc in ab
+func addAccBal(ab AccBal, acc AccountName, amt Amount) {
+	if ab[acc] == nil {
+		ab[acc] = make(CcyAmount, 1)
+	}
+	addToCcyAmount(ab[acc], amt)
+}
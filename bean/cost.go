@@ -0,0 +1,96 @@
+package bean
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// Cost is the cost basis of a Posting, written as {} or {{}}
+// eg {150 USD} or {{1500 USD}} or {150 USD, 2023-01-01, "lot1"}
+type Cost struct {
+	Amount *Amount   // per-unit cost, or total cost if Total. nil if not given
+	Total  bool      // true if written as {{}}
+	Date   time.Time // acquisition date, zero if not given
+	Label  string    // lot label, empty if not given
+}
+
+func (c Cost) String() string {
+	var parts []string
+	if c.Amount != nil {
+		parts = append(parts, c.Amount.String())
+	}
+	if !c.Date.IsZero() {
+		parts = append(parts, c.Date.Format(time.DateOnly))
+	}
+	if c.Label != "" {
+		parts = append(parts, fmt.Sprintf("%q", c.Label))
+	}
+	str := strings.Join(parts, ", ")
+	if c.Total {
+		return "{{" + str + "}}"
+	}
+	return "{" + str + "}"
+}
+
+// newCost creates a Cost from tokens starting with { or {{
+// and returns the number of tokens used
+func newCost(tokens []Token) (Cost, int, error) {
+	if debug {
+		log.Println("newCost", tokens[0].Text)
+	}
+	closing := TokenRCurl
+	cost := Cost{}
+	if tokens[0].Kind == TokenLCurlCurl {
+		closing = TokenRCurlCurl
+		cost.Total = true
+	}
+
+	// components are separated by commas
+	var component []Token
+	addComponent := func() error {
+		switch {
+		case len(component) == 0:
+			return nil
+		case len(component) == 2:
+			if cost.Amount != nil {
+				return fmt.Errorf("cost has multiple amounts")
+			}
+			amt, err := NewAmount(component[0].Text, component[1].Text)
+			if err != nil {
+				return fmt.Errorf("in newCost: %w", err)
+			}
+			cost.Amount = &amt
+		case len(component) == 1 && component[0].Kind == TokenString:
+			cost.Label = component[0].Text
+		case len(component) == 1:
+			date, err := getDate(component[0].Text)
+			if err != nil {
+				return fmt.Errorf("in newCost: %w", err)
+			}
+			cost.Date = date
+		default:
+			return fmt.Errorf("invalid cost component: %v", component)
+		}
+		component = nil
+		return nil
+	}
+
+	for i, t := range tokens[1:] {
+		switch t.Kind {
+		case closing:
+			if err := addComponent(); err != nil {
+				return Cost{}, 0, err
+			}
+			return cost, i + 2, nil
+		case TokenComma:
+			if err := addComponent(); err != nil {
+				return Cost{}, 0, err
+			}
+		default:
+			component = append(component, t)
+		}
+	}
+	return Cost{}, 0, fmt.Errorf("cost is missing closing %v", closing)
+}
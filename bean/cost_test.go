@@ -0,0 +1,60 @@
+package bean
+
+import (
+	"testing"
+	"time"
+)
+
+// tokensOf makes a Token of each text, with the Kind the lexer would give it
+func tokensOf(texts ...string) []Token {
+	tokens := make([]Token, len(texts))
+	for i, text := range texts {
+		lexed, _ := lex(text)
+		tokens[i] = Token{LineNum: 1, Kind: lexed[0].Kind, Text: text}
+	}
+	return tokens
+}
+
+func TestNewCost(t *testing.T) {
+	// all components should be parsed
+	tokens := tokensOf("{", "150", "USD", ",", "2023-01-01", ",", "lot1", "}", "@")
+	tokens[6].Kind = TokenString
+	got, n, err := newCost(tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 8 {
+		t.Errorf("want 8 tokens used, got %d", n)
+	}
+	if !got.Amount.Eq(MustNewAmount("150", "USD")) || got.Total {
+		t.Errorf("wrong cost amount: %v", got)
+	}
+	if got.Date != time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC) || got.Label != "lot1" {
+		t.Errorf("wrong cost date or label: %v", got)
+	}
+	if want := `{150 USD, 2023-01-01, "lot1"}`; got.String() != want {
+		t.Errorf("want %s, got %s", want, got)
+	}
+
+	// total and empty costs should be parsed
+	got, _, _ = newCost(tokensOf("{{", "1500", "USD", "}}"))
+	if !got.Total || !got.Amount.Eq(MustNewAmount("1500", "USD")) {
+		t.Errorf("wrong total cost: %v", got)
+	}
+	got, _, _ = newCost(tokensOf("{", "}"))
+	if got.Amount != nil {
+		t.Errorf("empty cost should have no amount: %v", got)
+	}
+
+	// invalid costs should error
+	for _, tokens := range [][]Token{
+		tokensOf("{", "150", "USD"),
+		tokensOf("{{", "150", "USD", "}"),
+		tokensOf("{", "foo", "}"),
+		tokensOf("{", "1", "USD", ",", "2", "USD", "}"),
+	} {
+		if _, _, err := newCost(tokens); err == nil {
+			t.Errorf("invalid cost should error: %v", tokens)
+		}
+	}
+}
@@ -4,39 +4,69 @@ import (
 	"fmt"
 	"log"
 	"time"
+
+	"github.com/cockroachdb/apd/v3"
 )
 
 // Balance statement
 type Balance struct {
-	Date    time.Time
-	Account Account
-	Amount  Amount
+	Date      time.Time
+	Account   Account
+	Amount    Amount
+	Tolerance *apd.Decimal // nil if not given with ~
+	Meta      Meta
+	Pos       Pos
 }
 
 func (b Balance) String() string {
+	if b.Tolerance != nil {
+		return fmt.Sprintf("%s balance %s %s ~ %s %s\n", b.Date.Format(time.DateOnly), b.Account.Name,
+			b.Amount.Number.Text('f'), b.Tolerance.Text('f'), b.Amount.Ccy)
+	}
 	return fmt.Sprintf("%s balance %s %v\n", b.Date.Format(time.DateOnly), b.Account.Name, b.Amount)
 }
 
 // newBalance creates a Balance from a Directive
 func newBalance(directive Directive) (Balance, error) {
-	line := directive.Lines[0] // TODO include metadata lines
-	log.Println("newBalance", line.Tokens[0].Text)
+	line := directive.Lines[0]
+	if debug {
+		log.Println("newBalance", line.Tokens[0].Text)
+	}
 	tokens := line.Tokens
+	// the tolerance is given as: NUMBER ~ TOLERANCE CCY
+	var tolerance *apd.Decimal
+	if len(tokens) == 7 && tokens[4].Text == "~" {
+		tol, _, err := apdCtx.NewFromString(tokens[5].Text)
+		if err != nil {
+			return Balance{}, fmt.Errorf("in newBalance: %w", err)
+		}
+		tolerance = tol
+		tokens = append(tokens[:4:4], tokens[6])
+	}
+	if len(tokens) != 5 {
+		return Balance{}, fmt.Errorf("balance must have an account and amount")
+	}
 	date, err := getDate(tokens[0].Text)
 	if err != nil {
 		return Balance{}, fmt.Errorf("in newBalance: %w", err)
 	}
 	account := tokens[2].Text
-	numberStr := tokens[3].Text
-	ccy := tokens[4].Text
-	if len(tokens) > 5 {
-		return Balance{}, fmt.Errorf("too many balance tokens: %s", directive)
+	amount, err := NewAmount(tokens[3].Text, tokens[4].Text)
+	if err != nil {
+		return Balance{}, fmt.Errorf("in newBalance: %w", err)
 	}
 
+	meta, err := newMeta(directive.Lines[1:])
+	if err != nil {
+		return Balance{}, fmt.Errorf("in newBalance: %w", err)
+	}
 	balance := Balance{
-		Date:    date,
-		Account: Account{AccountName(account)},
-		Amount:  MustNewAmount(numberStr, ccy),
+		Date:      date,
+		Account:   Account{AccountName(account)},
+		Amount:    amount,
+		Tolerance: tolerance,
+		Meta:      meta,
+		Pos:       directive.Pos(),
 	}
 	return balance, nil
 }
@@ -46,6 +76,8 @@ type Price struct {
 	Date   time.Time
 	Ccy    Ccy
 	Amount Amount
+	Meta   Meta
+	Pos    Pos
 }
 
 func (p Price) String() string {
@@ -55,19 +87,31 @@ func (p Price) String() string {
 // newPrice creates a Price
 func newPrice(directive Directive) (Price, error) {
 	tokens := directive.Lines[0].Tokens
-	log.Println("newPrice", tokens[0])
+	if debug {
+		log.Println("newPrice", tokens[0])
+	}
+	if len(tokens) != 5 {
+		return Price{}, fmt.Errorf("price must have a currency and amount")
+	}
 	date, err := getDate(tokens[0].Text)
 	if err != nil {
 		return Price{}, fmt.Errorf("in newPrice: %w", err)
 	}
 	ccy := tokens[2].Text
-	amtNum := tokens[3].Text
-	amtCcy := tokens[4].Text
-	amt := MustNewAmount(amtNum, amtCcy)
+	amt, err := NewAmount(tokens[3].Text, tokens[4].Text)
+	if err != nil {
+		return Price{}, fmt.Errorf("in newPrice: %w", err)
+	}
+	meta, err := newMeta(directive.Lines[1:])
+	if err != nil {
+		return Price{}, fmt.Errorf("in newPrice: %w", err)
+	}
 	price := Price{
 		Date:   date,
 		Ccy:    Ccy(ccy),
 		Amount: amt,
+		Meta:   meta,
+		Pos:    directive.Pos(),
 	}
 	return price, nil
 }
@@ -77,26 +121,293 @@ type Pad struct {
 	Date    time.Time
 	PadTo   Account
 	PadFrom Account
+	Meta    Meta
+	Pos     Pos
 }
 
 func (p Pad) String() string {
-	return fmt.Sprintf("%s pad %s %v\n", p.Date.Format(time.DateOnly), p.PadTo, p.PadFrom)
+	return fmt.Sprintf("%s pad %s %s\n", p.Date.Format(time.DateOnly), p.PadTo.Name, p.PadFrom.Name)
 }
 
 // newPad creates a Pad
 func newPad(directive Directive) (Pad, error) {
 	tokens := directive.Lines[0].Tokens
 	log.Println("newPad", tokens[0])
+	if len(tokens) != 4 {
+		return Pad{}, fmt.Errorf("pad must have two accounts")
+	}
 	date, err := getDate(tokens[0].Text)
 	if err != nil {
-		return Pad{}, fmt.Errorf("in newPrice: %w", err)
+		return Pad{}, fmt.Errorf("in newPad: %w", err)
 	}
 	padTo := Account{AccountName(tokens[2].Text)}
 	padFrom := Account{AccountName(tokens[3].Text)}
+	meta, err := newMeta(directive.Lines[1:])
+	if err != nil {
+		return Pad{}, fmt.Errorf("in newPad: %w", err)
+	}
 	pad := Pad{
 		Date:    date,
 		PadTo:   padTo,
 		PadFrom: padFrom,
+		Meta:    meta,
+		Pos:     directive.Pos(),
 	}
 	return pad, nil
 }
+
+// Note is a comment attached to an account on a date
+type Note struct {
+	Date    time.Time
+	Account Account
+	Comment string
+	Meta    Meta
+	Pos     Pos
+}
+
+func (n Note) String() string {
+	return fmt.Sprintf("%s note %s %q\n", n.Date.Format(time.DateOnly), n.Account.Name, n.Comment)
+}
+
+// newNote creates a Note
+func newNote(directive Directive) (Note, error) {
+	tokens := directive.Lines[0].Tokens
+	log.Println("newNote", tokens[0])
+	if len(tokens) != 4 || tokens[3].Kind != TokenString {
+		return Note{}, fmt.Errorf("note must have an account and a quoted comment")
+	}
+	date, err := getDate(tokens[0].Text)
+	if err != nil {
+		return Note{}, fmt.Errorf("in newNote: %w", err)
+	}
+	meta, err := newMeta(directive.Lines[1:])
+	if err != nil {
+		return Note{}, fmt.Errorf("in newNote: %w", err)
+	}
+	note := Note{
+		Date:    date,
+		Account: Account{AccountName(tokens[2].Text)},
+		Comment: tokens[3].Text,
+		Meta:    meta,
+		Pos:     directive.Pos(),
+	}
+	return note, nil
+}
+
+// Document links a file to an account on a date
+type Document struct {
+	Date    time.Time
+	Account Account
+	Path    string
+	Tags    Set // nil if there are none
+	Links   Set // nil if there are none
+	Meta    Meta
+	Pos     Pos
+}
+
+func (d Document) String() string {
+	return fmt.Sprintf("%s document %s %q\n", d.Date.Format(time.DateOnly), d.Account.Name, d.Path)
+}
+
+// newDocument creates a Document
+func newDocument(directive Directive) (Document, error) {
+	tokens := directive.Lines[0].Tokens
+	log.Println("newDocument", tokens[0])
+	if len(tokens) < 4 || tokens[3].Kind != TokenString {
+		return Document{}, fmt.Errorf("document must have an account and a quoted path")
+	}
+	date, err := getDate(tokens[0].Text)
+	if err != nil {
+		return Document{}, fmt.Errorf("in newDocument: %w", err)
+	}
+	var tags, links Set
+	for _, t := range tokens[4:] {
+		if !addTagOrLink(&tags, &links, t) {
+			return Document{}, fmt.Errorf("unexpected token in document: %s", t.Text)
+		}
+	}
+	meta, err := newMeta(directive.Lines[1:])
+	if err != nil {
+		return Document{}, fmt.Errorf("in newDocument: %w", err)
+	}
+	document := Document{
+		Date:    date,
+		Account: Account{AccountName(tokens[2].Text)},
+		Path:    tokens[3].Text,
+		Tags:    tags,
+		Links:   links,
+		Meta:    meta,
+		Pos:     directive.Pos(),
+	}
+	return document, nil
+}
+
+// Event records the value of a named variable (eg location) from a date
+type Event struct {
+	Date  time.Time
+	Name  string
+	Value string
+	Meta  Meta
+	Pos   Pos
+}
+
+func (e Event) String() string {
+	return fmt.Sprintf("%s event %q %q\n", e.Date.Format(time.DateOnly), e.Name, e.Value)
+}
+
+// newEvent creates an Event
+func newEvent(directive Directive) (Event, error) {
+	tokens := directive.Lines[0].Tokens
+	log.Println("newEvent", tokens[0])
+	if len(tokens) != 4 || tokens[2].Kind != TokenString || tokens[3].Kind != TokenString {
+		return Event{}, fmt.Errorf("event must have a quoted name and value")
+	}
+	date, err := getDate(tokens[0].Text)
+	if err != nil {
+		return Event{}, fmt.Errorf("in newEvent: %w", err)
+	}
+	meta, err := newMeta(directive.Lines[1:])
+	if err != nil {
+		return Event{}, fmt.Errorf("in newEvent: %w", err)
+	}
+	event := Event{
+		Date:  date,
+		Name:  tokens[2].Text,
+		Value: tokens[3].Text,
+		Meta:  meta,
+		Pos:   directive.Pos(),
+	}
+	return event, nil
+}
+
+// Commodity declares a currency or commodity
+type Commodity struct {
+	Date time.Time
+	Ccy  Ccy
+	Meta Meta
+	Pos  Pos
+}
+
+func (c Commodity) String() string {
+	return fmt.Sprintf("%s commodity %s\n", c.Date.Format(time.DateOnly), c.Ccy)
+}
+
+// newCommodity creates a Commodity
+func newCommodity(directive Directive) (Commodity, error) {
+	tokens := directive.Lines[0].Tokens
+	log.Println("newCommodity", tokens[0])
+	if len(tokens) != 3 {
+		return Commodity{}, fmt.Errorf("commodity must have a single currency")
+	}
+	date, err := getDate(tokens[0].Text)
+	if err != nil {
+		return Commodity{}, fmt.Errorf("in newCommodity: %w", err)
+	}
+	meta, err := newMeta(directive.Lines[1:])
+	if err != nil {
+		return Commodity{}, fmt.Errorf("in newCommodity: %w", err)
+	}
+	commodity := Commodity{
+		Date: date,
+		Ccy:  Ccy(tokens[2].Text),
+		Meta: meta,
+		Pos:  directive.Pos(),
+	}
+	return commodity, nil
+}
+
+// Query is a named query stored in the ledger
+type Query struct {
+	Date time.Time
+	Name string
+	SQL  string
+	Meta Meta
+	Pos  Pos
+}
+
+func (q Query) String() string {
+	return fmt.Sprintf("%s query %q %q\n", q.Date.Format(time.DateOnly), q.Name, q.SQL)
+}
+
+// newQuery creates a Query
+func newQuery(directive Directive) (Query, error) {
+	tokens := directive.Lines[0].Tokens
+	log.Println("newQuery", tokens[0])
+	if len(tokens) != 4 || tokens[2].Kind != TokenString || tokens[3].Kind != TokenString {
+		return Query{}, fmt.Errorf("query must have a quoted name and query")
+	}
+	date, err := getDate(tokens[0].Text)
+	if err != nil {
+		return Query{}, fmt.Errorf("in newQuery: %w", err)
+	}
+	meta, err := newMeta(directive.Lines[1:])
+	if err != nil {
+		return Query{}, fmt.Errorf("in newQuery: %w", err)
+	}
+	query := Query{
+		Date: date,
+		Name: tokens[2].Text,
+		SQL:  tokens[3].Text,
+		Meta: meta,
+		Pos:  directive.Pos(),
+	}
+	return query, nil
+}
+
+// Custom is a directive for plugins and tools,
+// with a quoted type and any number of typed values
+type Custom struct {
+	Date   time.Time
+	Type   string
+	Values []MetaValue
+	Meta   Meta
+	Pos    Pos
+}
+
+func (c Custom) String() string {
+	str := fmt.Sprintf("%s custom %q", c.Date.Format(time.DateOnly), c.Type)
+	for _, v := range c.Values {
+		str += " " + v.String()
+	}
+	return str + "\n"
+}
+
+// newCustom creates a Custom
+func newCustom(directive Directive) (Custom, error) {
+	tokens := directive.Lines[0].Tokens
+	log.Println("newCustom", tokens[0])
+	if len(tokens) < 3 || tokens[2].Kind != TokenString {
+		return Custom{}, fmt.Errorf("custom must have a quoted type")
+	}
+	date, err := getDate(tokens[0].Text)
+	if err != nil {
+		return Custom{}, fmt.Errorf("in newCustom: %w", err)
+	}
+	var values []MetaValue
+	rest := tokens[3:]
+	for len(rest) > 0 {
+		// a number followed by a currency is an amount
+		n := 1
+		if len(rest) >= 2 && rest[0].Kind == TokenNumber && rest[1].Kind == TokenCurrency {
+			n = 2
+		}
+		value, err := newMetaValue(rest[:n])
+		if err != nil {
+			return Custom{}, fmt.Errorf("in newCustom: %w", err)
+		}
+		values = append(values, value)
+		rest = rest[n:]
+	}
+	meta, err := newMeta(directive.Lines[1:])
+	if err != nil {
+		return Custom{}, fmt.Errorf("in newCustom: %w", err)
+	}
+	custom := Custom{
+		Date:   date,
+		Type:   tokens[2].Text,
+		Values: values,
+		Meta:   meta,
+		Pos:    directive.Pos(),
+	}
+	return custom, nil
+}
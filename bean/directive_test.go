@@ -25,7 +25,7 @@ func TestNewBalance(t *testing.T) {
 	acc := "Assets:Bank"
 	num := "100"
 	ccy := "GBP"
-	directive := Directive{[]Line{
+	directive := Directive{Lines: []Line{
 		{Tokens: []Token{
 			{LineNum: 1, Text: "2023-01-01"},
 			{LineNum: 1, Text: "balance"},
@@ -40,3 +40,45 @@ func TestNewBalance(t *testing.T) {
 		t.Error("too many balance tokens should error")
 	}
 }
+
+func Test_newCustom(t *testing.T) {
+	directive := Directive{Lines: []Line{
+		{Tokens: tokensOf("2023-01-01", "custom", "budget", "Expenses:Food", "monthly", "100", "GBP", "TRUE")},
+	}}
+	directive.Lines[0].Tokens[2].Kind = TokenString
+	directive.Lines[0].Tokens[4].Kind = TokenString
+	got, err := newCustom(directive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kinds []MetaKind
+	for _, v := range got.Values {
+		kinds = append(kinds, v.Kind)
+	}
+	if diff := cmp.Diff([]MetaKind{MetaAccount, MetaString, MetaAmount, MetaBool}, kinds); diff != "" {
+		t.Error(diff)
+	}
+	if got.Type != "budget" || got.Values[2].Amount.String() != "100 GBP" {
+		t.Errorf("wrong custom: %v", got)
+	}
+
+	// the type must be quoted
+	directive.Lines[0].Tokens[2].Kind = TokenWord
+	if _, err := newCustom(directive); err == nil {
+		t.Error("unquoted type should error")
+	}
+}
+
+func Test_newDocument(t *testing.T) {
+	directive := Directive{Lines: []Line{
+		{Tokens: tokensOf("2023-01-01", "document", "Assets:Bank", "statement.pdf", "#bank", "^jan")},
+	}}
+	directive.Lines[0].Tokens[3].Kind = TokenString
+	got, err := newDocument(directive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Path != "statement.pdf" || !got.Tags.Has("bank") || !got.Links.Has("jan") {
+		t.Errorf("wrong document: %v %v %v", got, got.Tags, got.Links)
+	}
+}
@@ -0,0 +1,260 @@
+package bean
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Entry is implemented by every dated directive type:
+// AccountEvent, Balance, Transaction, Price, Pad, Note,
+// Document, Event, Commodity, Query and Custom
+type Entry interface {
+	fmt.Stringer
+	EntryDate() time.Time
+	EntryMeta() Meta
+	EntryPos() Pos
+}
+
+// EntryDate returns the date of the AccountEvent
+func (ae AccountEvent) EntryDate() time.Time { return ae.Date }
+
+// EntryMeta returns the metadata of the AccountEvent
+func (ae AccountEvent) EntryMeta() Meta { return ae.Meta }
+
+// EntryPos returns the source position of the AccountEvent
+func (ae AccountEvent) EntryPos() Pos { return ae.Pos }
+
+// EntryDate returns the date of the Balance
+func (b Balance) EntryDate() time.Time { return b.Date }
+
+// EntryMeta returns the metadata of the Balance
+func (b Balance) EntryMeta() Meta { return b.Meta }
+
+// EntryPos returns the source position of the Balance
+func (b Balance) EntryPos() Pos { return b.Pos }
+
+// EntryDate returns the date of the Transaction
+func (t Transaction) EntryDate() time.Time { return t.Date }
+
+// EntryMeta returns the metadata of the Transaction
+func (t Transaction) EntryMeta() Meta { return t.Meta }
+
+// EntryPos returns the source position of the Transaction
+func (t Transaction) EntryPos() Pos { return t.Pos }
+
+// EntryDate returns the date of the Price
+func (p Price) EntryDate() time.Time { return p.Date }
+
+// EntryMeta returns the metadata of the Price
+func (p Price) EntryMeta() Meta { return p.Meta }
+
+// EntryPos returns the source position of the Price
+func (p Price) EntryPos() Pos { return p.Pos }
+
+// EntryDate returns the date of the Pad
+func (p Pad) EntryDate() time.Time { return p.Date }
+
+// EntryMeta returns the metadata of the Pad
+func (p Pad) EntryMeta() Meta { return p.Meta }
+
+// EntryPos returns the source position of the Pad
+func (p Pad) EntryPos() Pos { return p.Pos }
+
+// EntryDate returns the date of the Note
+func (n Note) EntryDate() time.Time { return n.Date }
+
+// EntryMeta returns the metadata of the Note
+func (n Note) EntryMeta() Meta { return n.Meta }
+
+// EntryPos returns the source position of the Note
+func (n Note) EntryPos() Pos { return n.Pos }
+
+// EntryDate returns the date of the Document
+func (d Document) EntryDate() time.Time { return d.Date }
+
+// EntryMeta returns the metadata of the Document
+func (d Document) EntryMeta() Meta { return d.Meta }
+
+// EntryPos returns the source position of the Document
+func (d Document) EntryPos() Pos { return d.Pos }
+
+// EntryDate returns the date of the Event
+func (e Event) EntryDate() time.Time { return e.Date }
+
+// EntryMeta returns the metadata of the Event
+func (e Event) EntryMeta() Meta { return e.Meta }
+
+// EntryPos returns the source position of the Event
+func (e Event) EntryPos() Pos { return e.Pos }
+
+// EntryDate returns the date of the Commodity
+func (c Commodity) EntryDate() time.Time { return c.Date }
+
+// EntryMeta returns the metadata of the Commodity
+func (c Commodity) EntryMeta() Meta { return c.Meta }
+
+// EntryPos returns the source position of the Commodity
+func (c Commodity) EntryPos() Pos { return c.Pos }
+
+// EntryDate returns the date of the Query
+func (q Query) EntryDate() time.Time { return q.Date }
+
+// EntryMeta returns the metadata of the Query
+func (q Query) EntryMeta() Meta { return q.Meta }
+
+// EntryPos returns the source position of the Query
+func (q Query) EntryPos() Pos { return q.Pos }
+
+// EntryDate returns the date of the Custom
+func (c Custom) EntryDate() time.Time { return c.Date }
+
+// EntryMeta returns the metadata of the Custom
+func (c Custom) EntryMeta() Meta { return c.Meta }
+
+// EntryPos returns the source position of the Custom
+func (c Custom) EntryPos() Pos { return c.Pos }
+
+// entryPriority orders Entries on the same date, as in beancount:
+// opens first, then balances (which apply at the start of the day),
+// then everything else, then documents, and closes last
+func entryPriority(e Entry) int {
+	switch e := e.(type) {
+	case AccountEvent:
+		if e.Open {
+			return -2
+		}
+		return 2
+	case Balance:
+		return -1
+	case Document:
+		return 1
+	}
+	return 0
+}
+
+// Entries returns every Entry in the Ledger in beancount's canonical order:
+// by date, then type priority, then file order
+func (l *Ledger) Entries() []Entry {
+	n := len(l.AccountEvents) + len(l.Balances) + len(l.Transactions) + len(l.Prices) + len(l.Pads) +
+		len(l.Notes) + len(l.Documents) + len(l.Events) + len(l.Commodities) + len(l.Queries) + len(l.Customs)
+	entries := make([]Entry, 0, n)
+	// pads come before transactions so that pad transactions
+	// (with the same position) come after their pad
+	for _, e := range l.AccountEvents {
+		entries = append(entries, e)
+	}
+	for _, e := range l.Commodities {
+		entries = append(entries, e)
+	}
+	for _, e := range l.Pads {
+		entries = append(entries, e)
+	}
+	for _, e := range l.Balances {
+		entries = append(entries, e)
+	}
+	for _, e := range l.Transactions {
+		entries = append(entries, e)
+	}
+	for _, e := range l.Prices {
+		entries = append(entries, e)
+	}
+	for _, e := range l.Notes {
+		entries = append(entries, e)
+	}
+	for _, e := range l.Documents {
+		entries = append(entries, e)
+	}
+	for _, e := range l.Events {
+		entries = append(entries, e)
+	}
+	for _, e := range l.Queries {
+		entries = append(entries, e)
+	}
+	for _, e := range l.Customs {
+		entries = append(entries, e)
+	}
+
+	files := make(map[string]int, len(l.Files))
+	for i, f := range l.Files {
+		files[f] = i
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if !a.EntryDate().Equal(b.EntryDate()) {
+			return a.EntryDate().Before(b.EntryDate())
+		}
+		if pa, pb := entryPriority(a), entryPriority(b); pa != pb {
+			return pa < pb
+		}
+		posA, posB := a.EntryPos(), b.EntryPos()
+		if posA.File != posB.File {
+			return files[posA.File] < files[posB.File]
+		}
+		return posA.Line < posB.Line
+	})
+	return entries
+}
+
+// Visitor has a func for each type of Entry, to walk Entries
+// without type switches. Nil funcs are skipped.
+type Visitor struct {
+	AccountEvent func(AccountEvent) error
+	Balance      func(Balance) error
+	Transaction  func(Transaction) error
+	Price        func(Price) error
+	Pad          func(Pad) error
+	Note         func(Note) error
+	Document     func(Document) error
+	Event        func(Event) error
+	Commodity    func(Commodity) error
+	Query        func(Query) error
+	Custom       func(Custom) error
+}
+
+// Visit calls the func in the Visitor for the type of e (if not nil)
+func (v Visitor) Visit(e Entry) error {
+	switch e := e.(type) {
+	case AccountEvent:
+		return call(v.AccountEvent, e)
+	case Balance:
+		return call(v.Balance, e)
+	case Transaction:
+		return call(v.Transaction, e)
+	case Price:
+		return call(v.Price, e)
+	case Pad:
+		return call(v.Pad, e)
+	case Note:
+		return call(v.Note, e)
+	case Document:
+		return call(v.Document, e)
+	case Event:
+		return call(v.Event, e)
+	case Commodity:
+		return call(v.Commodity, e)
+	case Query:
+		return call(v.Query, e)
+	case Custom:
+		return call(v.Custom, e)
+	}
+	return fmt.Errorf("unknown entry type %T", e)
+}
+
+func call[T Entry](f func(T) error, e T) error {
+	if f == nil {
+		return nil
+	}
+	return f(e)
+}
+
+// Walk visits every Entry in the Ledger in canonical order,
+// stopping at the first error
+func (l *Ledger) Walk(v Visitor) error {
+	for _, e := range l.Entries() {
+		if err := v.Visit(e); err != nil {
+			return fmt.Errorf("%v: %w", e.EntryPos(), err)
+		}
+	}
+	return nil
+}
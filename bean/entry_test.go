@@ -0,0 +1,57 @@
+package bean
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLedger_Entries(t *testing.T) {
+	text := `
+2023-01-02 close Assets:Bank
+2023-01-02 note Assets:Bank "closing"
+2023-01-02 document Assets:Bank "statement.pdf"
+2023-01-02 balance Assets:Bank 0 GBP
+2023-01-01 commodity GBP
+2023-01-01 open Assets:Bank
+2023-01-01 event "location" "Paris"
+2023-01-01 query "cash" "SELECT account"
+2023-01-01 custom "budget" Assets:Bank 10 GBP
+2023-01-01 price GOO 10 GBP
+`
+	l := NewLedger(false)
+	if _, err := l.parse(io.NopCloser(strings.NewReader(text))); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range l.Entries() {
+		got = append(got, strings.TrimSpace(e.String()))
+	}
+	want := []string{
+		"2023-01-01 open Assets:Bank",
+		"2023-01-01 commodity GBP",
+		`2023-01-01 event "location" "Paris"`,
+		`2023-01-01 query "cash" "SELECT account"`,
+		`2023-01-01 custom "budget" Assets:Bank 10 GBP`,
+		"2023-01-01 price GOO 10 GBP",
+		"2023-01-02 balance Assets:Bank 0 GBP",
+		`2023-01-02 note Assets:Bank "closing"`,
+		`2023-01-02 document Assets:Bank "statement.pdf"`,
+		"2023-01-02 close Assets:Bank",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Error(diff)
+	}
+
+	// the visitor should only call the funcs that are set
+	var notes, events int
+	err := l.Walk(Visitor{
+		Note:  func(Note) error { notes++; return nil },
+		Event: func(Event) error { events++; return nil },
+	})
+	if err != nil || notes != 1 || events != 1 {
+		t.Errorf("wrong visits: %d notes, %d events, %v", notes, events, err)
+	}
+}
@@ -0,0 +1,205 @@
+package bean
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Severity is how serious an Error is
+type Severity int
+
+// Severities of errors found while loading
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	if s == SeverityWarning {
+		return "warning"
+	}
+	return "error"
+}
+
+// MarshalText renders the Severity as its name, eg in JSON
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Error is a problem found while loading a Ledger,
+// at a position in the source files
+type Error struct {
+	Pos       Pos
+	Column    int // 0 if not known
+	Severity  Severity
+	Message   string
+	Directive string // source text of the offending directive, if known
+	err       error  // underlying error, if any
+}
+
+func (e *Error) Error() string {
+	if e.Pos == (Pos{}) {
+		return e.Message
+	}
+	pos := e.Pos.String()
+	if e.Column > 0 {
+		pos = fmt.Sprintf("%s:%d", pos, e.Column)
+	}
+	return fmt.Sprintf("%s: %s", pos, e.Message)
+}
+
+// MarshalJSON renders the Error with a flat position,
+// eg for editor integrations
+func (e Error) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		File      string   `json:"file,omitempty"`
+		Line      int      `json:"line,omitempty"`
+		Column    int      `json:"column,omitempty"`
+		Severity  Severity `json:"severity"`
+		Message   string   `json:"message"`
+		Directive string   `json:"directive,omitempty"`
+	}{e.Pos.File, e.Pos.Line, e.Column, e.Severity, e.Message, e.Directive})
+}
+
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+// errorAt creates an Error at pos, formatted as with fmt.Errorf
+func errorAt(pos Pos, format string, a ...any) *Error {
+	err := fmt.Errorf(format, a...)
+	return &Error{Pos: pos, Message: err.Error(), err: errors.Unwrap(err)}
+}
+
+// errorFrom creates an Error for err at pos
+func errorFrom(pos Pos, err error) *Error {
+	return &Error{Pos: pos, Message: message(err), err: err}
+}
+
+// directiveError creates an Error for err at the start of directive
+func directiveError(directive Directive, err error) *Error {
+	return &Error{
+		Pos:       directive.Pos(),
+		Column:    int(directive.Lines[0].Tokens[0].Column),
+		Message:   message(err),
+		Directive: directive.Text(),
+		err:       err,
+	}
+}
+
+// collectErrors flattens (joined) errors into a slice of Error.
+// Errors without a position are kept with an empty Pos.
+func collectErrors(err error) []Error {
+	if err == nil {
+		return nil
+	}
+	if e, ok := err.(*Error); ok {
+		return []Error{*e}
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var res []Error
+		for _, err := range joined.Unwrap() {
+			res = append(res, collectErrors(err)...)
+		}
+		return res
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return []Error{*e}
+	}
+	return []Error{{Message: message(err), err: err}}
+}
+
+// message returns the text of err for users, without the
+// "in funcName: " prefixes added as it was passed up
+func message(err error) string {
+	msg := err.Error()
+	for strings.HasPrefix(msg, "in ") {
+		name, rest, ok := strings.Cut(msg[len("in "):], ": ")
+		if !ok || !isFuncName(name) {
+			break
+		}
+		msg = rest
+	}
+	return msg
+}
+
+// isFuncName returns true if name could be a Go func name
+func isFuncName(name string) bool {
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if !isUpper(c) && !isDigit(c) && (c < 'a' || c > 'z') && c != '_' {
+			return false
+		}
+	}
+	return name != "" && !isDigit(name[0])
+}
+
+// LoadResult is the outcome of loading a Ledger: the Ledger built from
+// every valid entry, and every Error and warning found along the way
+type LoadResult struct {
+	Ledger *Ledger
+	Errors []Error // sorted by position
+}
+
+// HasErrors returns true if any of the Errors are not just warnings
+func (r LoadResult) HasErrors() bool {
+	for _, e := range r.Errors {
+		if e.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Err returns all the Errors that are not just warnings joined together,
+// or nil if there are none
+func (r LoadResult) Err() error {
+	var errs []error
+	for i := range r.Errors {
+		if r.Errors[i].Severity == SeverityError {
+			errs = append(errs, &r.Errors[i])
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// newLoadResult collects the errors from loading into a LoadResult,
+// filling in the directive text from the directives where missing
+func newLoadResult(l *Ledger, directives []Directive, err error) LoadResult {
+	errs := collectErrors(err)
+	for _, w := range l.Warnings {
+		for _, e := range collectErrors(w) {
+			e.Severity = SeverityWarning
+			errs = append(errs, e)
+		}
+	}
+
+	if len(errs) == 0 {
+		return LoadResult{Ledger: l}
+	}
+	byPos := make(map[Pos]Directive, len(directives))
+	for _, d := range directives {
+		byPos[d.Pos()] = d
+	}
+	for i, e := range errs {
+		if d, ok := byPos[e.Pos]; ok {
+			if e.Directive == "" {
+				errs[i].Directive = d.Text()
+			}
+			if e.Column == 0 {
+				errs[i].Column = int(d.Lines[0].Tokens[0].Column)
+			}
+		}
+	}
+	sort.SliceStable(errs, func(i, j int) bool {
+		if errs[i].Pos.File != errs[j].Pos.File {
+			return errs[i].Pos.File < errs[j].Pos.File
+		}
+		return errs[i].Pos.Line < errs[j].Pos.Line
+	})
+	return LoadResult{Ledger: l, Errors: errs}
+}
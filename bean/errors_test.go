@@ -0,0 +1,77 @@
+package bean
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestError(t *testing.T) {
+	e := errorAt(Pos{File: "main.bean", Line: 3}, "in x: %w", errors.New("bad"))
+	if got := e.Error(); got != "main.bean:3: in x: bad" {
+		t.Errorf("wrong message: %q", got)
+	}
+	e.Column = 5
+	if got := e.Error(); got != "main.bean:3:5: in x: bad" {
+		t.Errorf("wrong message: %q", got)
+	}
+	if errors.Unwrap(e) == nil {
+		t.Error("Error should unwrap")
+	}
+
+	// JSON should have a flat position
+	e.Severity = SeverityWarning
+	got, err := json.Marshal([]Error{*e})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `[{"file":"main.bean","line":3,"column":5,"severity":"warning","message":"in x: bad"}]`
+	if string(got) != want {
+		t.Errorf("wrong JSON: %s", got)
+	}
+}
+
+func Test_collectErrors(t *testing.T) {
+	err := errors.Join(
+		errorAt(Pos{Line: 1}, "one"),
+		fmt.Errorf("in x: %w", errorAt(Pos{Line: 2}, "two")),
+		errors.New("three"),
+		nil,
+	)
+	got := collectErrors(err)
+	if len(got) != 3 {
+		t.Fatalf("want 3 errors, got %v", got)
+	}
+	if got[0].Pos.Line != 1 || got[1].Pos.Line != 2 || got[2].Message != "three" {
+		t.Errorf("wrong errors: %v", got)
+	}
+	if collectErrors(nil) != nil {
+		t.Error("nil should give no errors")
+	}
+}
+
+func Test_message(t *testing.T) {
+	tests := map[string]error{
+		"bad":                    fmt.Errorf("in a: %w", fmt.Errorf("in newB: %w", errors.New("bad"))),
+		"in 2023: not a wrap":    errors.New("in 2023: not a wrap"),
+		"within limits: not one": errors.New("within limits: not one"),
+		"unexpected in x: y":     errors.New("unexpected in x: y"),
+	}
+	for want, err := range tests {
+		if got := message(err); got != want {
+			t.Errorf("want %q, got %q", want, got)
+		}
+	}
+}
+
+func TestLoadResult(t *testing.T) {
+	res := LoadResult{Errors: []Error{{Severity: SeverityWarning, Message: "w"}}}
+	if res.HasErrors() || res.Err() != nil {
+		t.Error("warnings are not errors")
+	}
+	res.Errors = append(res.Errors, Error{Message: "e"})
+	if !res.HasErrors() || res.Err() == nil {
+		t.Error("should have errors")
+	}
+}
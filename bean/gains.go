@@ -0,0 +1,163 @@
+package bean
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Disposal is a sale (with a price) of units from a lot held at cost
+type Disposal struct {
+	Date      time.Time // date of the sale
+	Acquired  time.Time // acquisition date of the lot
+	Account   Account
+	Narration string
+	Units     Amount // units disposed, always positive
+	Proceeds  Amount
+	CostBasis Amount
+	Gain      Amount // negative for a loss
+	txIndex   int    // index into Ledger.Transactions
+}
+
+func (d Disposal) String() string {
+	return fmt.Sprintf("%s %s %v acquired %s proceeds %v cost %v gain %v\n",
+		d.Date.Format(time.DateOnly), d.Account.Name, d.Units, d.Acquired.Format(time.DateOnly),
+		d.Proceeds, d.CostBasis, d.Gain)
+}
+
+// HoldingDays is the number of days the lot was held
+func (d Disposal) HoldingDays() int {
+	return int(d.Date.Sub(d.Acquired).Hours() / 24)
+}
+
+// LongTerm returns true if the lot was held for more than a year
+func (d Disposal) LongTerm() bool {
+	return d.Date.After(d.Acquired.AddDate(1, 0, 0))
+}
+
+// newDisposal creates a Disposal for a booked reduction from a Posting with a price.
+// ok is false if the reduction is not a sale (no price) or the price
+// and cost are in different currencies.
+func newDisposal(tx Transaction, p Posting, reduction Position) (Disposal, bool) {
+	if p.Price == nil || p.Price.Ccy != reduction.Lot.Cost.Ccy {
+		return Disposal{}, false
+	}
+	units := reduction.Units.Abs()
+	proceeds := p.Price.Mul(units.Number)
+	if p.PriceTotal {
+		proceeds = *p.Price
+	}
+	costBasis := reduction.Lot.Cost.Mul(units.Number)
+	gain := proceeds.MustAdd(costBasis.Neg())
+	if !reduction.Units.Number.Negative {
+		// closing a short position
+		gain = gain.Neg()
+	}
+	return Disposal{
+		Date:      tx.Date,
+		Acquired:  reduction.Lot.Date,
+		Account:   p.Account,
+		Narration: tx.Narration,
+		Units:     units,
+		Proceeds:  proceeds,
+		CostBasis: costBasis,
+		Gain:      gain,
+	}, true
+}
+
+// isGainsAccount returns true for Income accounts with Gains in their name
+// eg Income:Invest:Gains or Income:CapitalGains
+func isGainsAccount(name AccountName, incomeRoot string) bool {
+	parts := strings.Split(string(name), ":")
+	if parts[0] != incomeRoot {
+		return false
+	}
+	for _, part := range parts[1:] {
+		if strings.Contains(part, "Gains") {
+			return true
+		}
+	}
+	return false
+}
+
+// checkGains checks that the gains leg of each (balanced) Transaction
+// with Disposals matches the realized gain, within the tolerance
+// inferred from its Postings.
+// Transactions without a gains leg are not checked.
+func checkGains(transactions []Transaction, disposals []Disposal, opts Options) error {
+	gains := make(map[int]CcyAmount, len(disposals))
+	var indices []int
+	for _, d := range disposals {
+		if gains[d.txIndex] == nil {
+			gains[d.txIndex] = make(CcyAmount, 1)
+			indices = append(indices, d.txIndex)
+		}
+		addToCcyAmount(gains[d.txIndex], d.Gain)
+	}
+
+	var errs []error
+	for _, i := range indices {
+		tx := transactions[i]
+		gain := gains[i]
+		legs := make(CcyAmount, 1)
+		for _, p := range tx.Postings {
+			if isGainsAccount(p.Account.Name, opts.NameIncome) {
+				addToCcyAmount(legs, p.Amount.Neg())
+			}
+		}
+		if len(legs) == 0 {
+			continue
+		}
+		tols := inferTolerances(tx.Postings, opts)
+		for ccy, amt := range gain {
+			leg, ok := legs[ccy]
+			if !ok {
+				leg = Amount{Ccy: ccy}
+			}
+			if !within(leg.MustAdd(amt.Neg()), tols.get(ccy, opts)) {
+				errs = append(errs, errorAt(tx.Pos, "gains leg %v does not match realized gain %v",
+					leg, amt))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// GainsYear is all the Disposals in a tax year, with totals
+type GainsYear struct {
+	Year      int
+	Disposals []Disposal
+	Proceeds  CcyAmount
+	CostBasis CcyAmount
+	Gain      CcyAmount
+}
+
+// getGainsYears groups the Disposals by (calendar) tax year,
+// sorted by year and then by date
+func getGainsYears(disposals []Disposal) []GainsYear {
+	sorted := make([]Disposal, len(disposals))
+	copy(sorted, disposals)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Date.Before(sorted[j].Date)
+	})
+
+	var years []GainsYear
+	for _, d := range sorted {
+		if len(years) == 0 || years[len(years)-1].Year != d.Date.Year() {
+			years = append(years, GainsYear{
+				Year:      d.Date.Year(),
+				Proceeds:  make(CcyAmount, 1),
+				CostBasis: make(CcyAmount, 1),
+				Gain:      make(CcyAmount, 1),
+			})
+		}
+		year := &years[len(years)-1]
+		year.Disposals = append(year.Disposals, d)
+		addToCcyAmount(year.Proceeds, d.Proceeds)
+		addToCcyAmount(year.CostBasis, d.CostBasis)
+		addToCcyAmount(year.Gain, d.Gain)
+	}
+	return years
+}
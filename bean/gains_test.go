@@ -0,0 +1,85 @@
+package bean
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_newDisposal(t *testing.T) {
+	tx := Transaction{Date: time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)}
+	price := MustNewAmount("150", "USD")
+	p := Posting{Account: Account{"Assets:Invest"}, Price: &price}
+	reduction := Position{Units: MustNewAmount("-5", "GOO"), Lot: testLot("100", time.January)}
+
+	got, ok := newDisposal(tx, p, reduction)
+	if !ok {
+		t.Fatal("sale with price should be a disposal")
+	}
+	if !got.Proceeds.Eq(MustNewAmount("750", "USD")) ||
+		!got.CostBasis.Eq(MustNewAmount("500", "USD")) ||
+		!got.Gain.Eq(MustNewAmount("250", "USD")) {
+		t.Errorf("wrong disposal: %v", got)
+	}
+	if got.HoldingDays() != 425 || !got.LongTerm() {
+		t.Errorf("wrong holding period: %d", got.HoldingDays())
+	}
+
+	// reductions without a price are not disposals
+	p.Price = nil
+	if _, ok := newDisposal(tx, p, reduction); ok {
+		t.Error("reduction without price should not be a disposal")
+	}
+}
+
+func Test_checkGains(t *testing.T) {
+	opts := defaultOptions()
+	gainsLeg := MustNewAmount("-250", "USD")
+	transactions := []Transaction{{
+		Postings: []Posting{{Account: Account{"Income:Invest:Gains"}, Amount: &gainsLeg}},
+	}}
+	disposals := []Disposal{{Gain: MustNewAmount("250", "USD")}}
+	if err := checkGains(transactions, disposals, opts); err != nil {
+		t.Error(err)
+	}
+
+	// wrong gains leg should error
+	disposals[0].Gain = MustNewAmount("200", "USD")
+	if err := checkGains(transactions, disposals, opts); err == nil {
+		t.Error("wrong gains leg should error")
+	}
+
+	// a gains leg within the inferred tolerance is accepted
+	gainsLeg = MustNewAmount("-10.00", "USD")
+	disposals[0].Gain = MustNewAmount("9.999", "USD")
+	if err := checkGains(transactions, disposals, opts); err != nil {
+		t.Error(err)
+	}
+	disposals[0].Gain = MustNewAmount("9.99", "USD")
+	if err := checkGains(transactions, disposals, opts); err == nil {
+		t.Error("gains leg outside the tolerance should error")
+	}
+
+	// transactions without a gains leg are not checked
+	transactions[0].Postings[0].Account = Account{"Income:Dividends"}
+	if err := checkGains(transactions, disposals, opts); err != nil {
+		t.Error(err)
+	}
+}
+
+func Test_getGainsYears(t *testing.T) {
+	disposals := []Disposal{
+		{Date: time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC), Gain: MustNewAmount("10", "USD")},
+		{Date: time.Date(2023, time.March, 1, 0, 0, 0, 0, time.UTC), Gain: MustNewAmount("20", "USD")},
+		{Date: time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC), Gain: MustNewAmount("-5", "USD")},
+	}
+	got := getGainsYears(disposals)
+	if len(got) != 2 || got[0].Year != 2023 || got[1].Year != 2024 {
+		t.Fatalf("wrong years: %v", got)
+	}
+	if len(got[1].Disposals) != 2 || !got[1].Disposals[0].Date.Before(got[1].Disposals[1].Date) {
+		t.Errorf("disposals should be sorted by date: %v", got[1].Disposals)
+	}
+	if !got[1].Gain["USD"].Eq(MustNewAmount("5", "USD")) {
+		t.Errorf("wrong total gain: %v", got[1].Gain)
+	}
+}
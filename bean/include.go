@@ -0,0 +1,97 @@
+package bean
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// readFileDirectives reads all the Directives from the file at path,
+// and from any files it includes.
+// stack is the chain of (absolute) paths that included this file,
+// and is used to detect include cycles.
+func readFileDirectives(path string, stack []string) ([]Directive, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("in readFileDirectives: %w", err)
+	}
+	for i, p := range stack {
+		if p == abs {
+			return nil, fmt.Errorf("include cycle: %s -> %s", strings.Join(stack[i:], " -> "), abs)
+		}
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("in readFileDirectives: %w", err)
+	}
+	newStack := make([]string, len(stack), len(stack)+1)
+	copy(newStack, stack)
+	return readDirectives(file, path, append(newStack, abs))
+}
+
+// readDirectives reads all the Directives from rc, tagged with path
+// (which may be empty), and then the Directives from any files it includes.
+// Includes are relative to path, and may be glob patterns.
+// Errors are collected and returned along with all the valid Directives.
+func readDirectives(rc io.ReadCloser, path string, stack []string) ([]Directive, error) {
+	tokens, lexErr := getTokens(rc)
+	// makeLines never errors currently
+	lines, _ := makeLines(tokens)
+	debugSlice(lines, "lines")
+	directives, err := makeDirectives(lines)
+	var errs []error
+	for _, e := range collectErrors(errors.Join(lexErr, err)) {
+		e.Pos.File = path
+		errs = append(errs, &e)
+	}
+
+	res := make([]Directive, 0, len(directives))
+	var includes []Directive
+	for _, d := range directives {
+		d.File = path
+		if len(d.Lines) > 0 && d.Lines[0].Tokens[0].Text == string(dirInclude) {
+			includes = append(includes, d)
+		} else {
+			res = append(res, d)
+		}
+	}
+
+	for _, inc := range includes {
+		tokens := inc.Lines[0].Tokens
+		if len(tokens) != 2 || tokens[1].Kind != TokenString {
+			errs = append(errs, directiveError(inc, fmt.Errorf("include must have a single quoted path")))
+			continue
+		}
+		pattern := tokens[1].Text
+		if !filepath.IsAbs(pattern) && path != "" {
+			pattern = filepath.Join(filepath.Dir(path), pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			errs = append(errs, directiveError(inc, fmt.Errorf("invalid include pattern: %w", err)))
+			continue
+		}
+		if len(matches) == 0 {
+			errs = append(errs, directiveError(inc, fmt.Errorf("include %s matched no files", pattern)))
+			continue
+		}
+		for _, match := range matches {
+			log.Println("include", match)
+			included, err := readFileDirectives(match, stack)
+			if err != nil {
+				// errors inside the included file already have their position
+				var e *Error
+				if !errors.As(err, &e) {
+					err = directiveError(inc, err)
+				}
+				errs = append(errs, err)
+			}
+			res = append(res, included...)
+		}
+	}
+	return res, errors.Join(errs...)
+}
@@ -0,0 +1,41 @@
+package bean
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_readFileDirectives(t *testing.T) {
+	// includes should be resolved relative to the including file
+	directives, err := readFileDirectives("testdata/include/main.bean", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string]int)
+	for _, d := range directives {
+		files[d.File]++
+	}
+	want := map[string]int{
+		"testdata/include/main.bean":     1,
+		"testdata/include/accounts.bean": 3,
+		"testdata/include/2023/01.bean":  1,
+		"testdata/include/2023/02.bean":  2,
+	}
+	for file, n := range want {
+		if files[file] != n {
+			t.Errorf("want %d directives from %s, got %d", n, file, files[file])
+		}
+	}
+
+	// cycles should error
+	_, err = readFileDirectives("testdata/cycle/a.bean", nil)
+	if err == nil || !strings.Contains(err.Error(), "include cycle") {
+		t.Errorf("include cycle should error, got %v", err)
+	}
+
+	// missing includes should error
+	_, err = readFileDirectives("testdata/include/missing.bean", nil)
+	if err == nil {
+		t.Error("missing file should error")
+	}
+}
@@ -0,0 +1,302 @@
+package bean
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Lot is the cost basis of some units held in an Inventory
+type Lot struct {
+	Cost  Amount    // per-unit cost
+	Date  time.Time // acquisition date
+	Label string
+}
+
+func (l Lot) String() string {
+	cost := Cost{Amount: &l.Cost, Date: l.Date, Label: l.Label}
+	return cost.String()
+}
+
+// eq returns true if both Lots have the same cost, date and label
+func (l Lot) eq(other Lot) bool {
+	return l.Cost.Eq(other.Cost) && l.Date.Equal(other.Date) && l.Label == other.Label
+}
+
+// newLot creates the Lot for units being added at cost,
+// taking the date from the Transaction if it isn't in the Cost
+func newLot(units Amount, cost Cost, date time.Time) (Lot, error) {
+	if cost.Amount == nil {
+		return Lot{}, fmt.Errorf("cost must have an amount when adding to an inventory: %v %v", units, cost)
+	}
+	perUnit := *cost.Amount
+	if cost.Total {
+		var err error
+		perUnit, err = perUnit.Quo(units.Abs().Number)
+		if err != nil {
+			return Lot{}, fmt.Errorf("in newLot: %w", err)
+		}
+	}
+	if !cost.Date.IsZero() {
+		date = cost.Date
+	}
+	return Lot{Cost: perUnit, Date: date, Label: cost.Label}, nil
+}
+
+// Position is some units of a commodity, held at cost if Lot is not nil
+type Position struct {
+	Units Amount
+	Lot   *Lot
+}
+
+func (p Position) String() string {
+	if p.Lot == nil {
+		return p.Units.String()
+	}
+	return fmt.Sprintf("%v %v", p.Units, p.Lot)
+}
+
+// Inventory is all the Positions held in an account
+type Inventory struct {
+	Positions []Position
+}
+
+// AccInv is a map of Account -> Inventory
+type AccInv = map[AccountName]*Inventory
+
+func (inv *Inventory) String() string {
+	str := ""
+	for _, p := range inv.Positions {
+		str += fmt.Sprintf("  %v\n", p)
+	}
+	return str
+}
+
+// Add adds units to the Position with the same Ccy and Lot,
+// or creates a new Position if there isn't one.
+// Positions that reach zero are removed.
+func (inv *Inventory) Add(units Amount, lot *Lot) {
+	for i, p := range inv.Positions {
+		if p.Units.Ccy != units.Ccy || (p.Lot == nil) != (lot == nil) {
+			continue
+		}
+		if lot != nil && !p.Lot.eq(*lot) {
+			continue
+		}
+		inv.Positions[i].Units = p.Units.MustAdd(units)
+		if inv.Positions[i].Units.Number.IsZero() {
+			inv.Positions = append(inv.Positions[:i], inv.Positions[i+1:]...)
+		}
+		return
+	}
+	if lot != nil {
+		lotCopy := *lot
+		lot = &lotCopy
+	}
+	inv.Positions = append(inv.Positions, Position{Units: units, Lot: lot})
+}
+
+// clone returns a copy of the Inventory that can be changed
+// without affecting inv, which may be nil
+func (inv *Inventory) clone() *Inventory {
+	if inv == nil {
+		return &Inventory{}
+	}
+	return &Inventory{Positions: append([]Position(nil), inv.Positions...)}
+}
+
+// Units returns the total units of each Ccy, ignoring cost
+func (inv *Inventory) Units() CcyAmount {
+	res := make(CcyAmount, len(inv.Positions))
+	for _, p := range inv.Positions {
+		addToCcyAmount(res, p.Units)
+	}
+	return res
+}
+
+// IsEmpty returns true if there are no Positions
+func (inv *Inventory) IsEmpty() bool {
+	return len(inv.Positions) == 0
+}
+
+// isReduction returns true if units would reduce existing lots
+// ie there are lots of the same Ccy with the opposite sign
+func (inv *Inventory) isReduction(units Amount) bool {
+	for _, p := range inv.Positions {
+		if p.Lot != nil && p.Units.Ccy == units.Ccy && p.Units.Number.Negative != units.Number.Negative {
+			return true
+		}
+	}
+	return false
+}
+
+// average merges all the lots of ccy into a single lot at their average cost,
+// dated at the earliest acquisition
+func (inv *Inventory) average(ccy Ccy) error {
+	var units, cost *Amount
+	var date time.Time
+	positions := make([]Position, 0, len(inv.Positions))
+	for _, p := range inv.Positions {
+		if p.Lot == nil || p.Units.Ccy != ccy {
+			positions = append(positions, p)
+			continue
+		}
+		if units == nil {
+			units = &Amount{Ccy: ccy}
+			cost = &Amount{Ccy: p.Lot.Cost.Ccy}
+			date = p.Lot.Date
+		}
+		if p.Lot.Cost.Ccy != cost.Ccy {
+			return fmt.Errorf("cannot average lots of %s with different cost currencies", ccy)
+		}
+		*units = units.MustAdd(p.Units)
+		*cost = cost.MustAdd(p.Lot.Cost.Mul(p.Units.Number))
+		if p.Lot.Date.Before(date) {
+			date = p.Lot.Date
+		}
+	}
+	if units == nil || units.Number.IsZero() {
+		return nil
+	}
+	perUnit, err := cost.Quo(units.Number)
+	if err != nil {
+		return fmt.Errorf("in average: %w", err)
+	}
+	inv.Positions = append(positions, Position{Units: *units, Lot: &Lot{Cost: perUnit, Date: date}})
+	return nil
+}
+
+// reduce matches units against the lots that match spec, using the booking method.
+// The units must have the opposite sign to the lots they reduce.
+// It returns the reductions (one per lot), which are not yet applied to the Inventory.
+// With AVERAGE booking the lots of units.Ccy are merged first,
+// but only if the reduction succeeds.
+func (inv *Inventory) reduce(units Amount, spec Cost, booking Booking) ([]Position, error) {
+	lots := inv
+	if booking == BookingAverage {
+		lots = inv.clone()
+		if err := lots.average(units.Ccy); err != nil {
+			return nil, fmt.Errorf("in reduce: %w", err)
+		}
+	}
+
+	var specCost *Amount
+	if spec.Amount != nil {
+		perUnit := *spec.Amount
+		if spec.Total {
+			var err error
+			perUnit, err = perUnit.Quo(units.Abs().Number)
+			if err != nil {
+				return nil, fmt.Errorf("in reduce: %w", err)
+			}
+		}
+		specCost = &perUnit
+	}
+
+	var matches []Position
+	for _, p := range lots.Positions {
+		if p.Lot == nil || p.Units.Ccy != units.Ccy || p.Units.Number.Negative == units.Number.Negative {
+			continue
+		}
+		if specCost != nil && booking != BookingAverage && !p.Lot.Cost.Eq(*specCost) {
+			continue
+		}
+		if !spec.Date.IsZero() && !spec.Date.Equal(p.Lot.Date) {
+			continue
+		}
+		if spec.Label != "" && spec.Label != p.Lot.Label {
+			continue
+		}
+		matches = append(matches, p)
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("no lots match reduction %v %v", units, spec)
+	}
+
+	wanted := units.Abs()
+	switch booking {
+	case BookingStrict:
+		if len(matches) > 1 {
+			total := Amount{Ccy: units.Ccy}
+			for _, p := range matches {
+				total = total.MustAdd(p.Units.Abs())
+			}
+			if !total.Eq(wanted) {
+				return nil, fmt.Errorf("ambiguous reduction %v %v matches %d lots", units, spec, len(matches))
+			}
+		}
+	case BookingFIFO:
+		sort.SliceStable(matches, func(i, j int) bool {
+			return matches[i].Lot.Date.Before(matches[j].Lot.Date)
+		})
+	case BookingLIFO:
+		sort.SliceStable(matches, func(i, j int) bool {
+			return matches[i].Lot.Date.After(matches[j].Lot.Date)
+		})
+	case BookingHIFO:
+		sort.SliceStable(matches, func(i, j int) bool {
+			return matches[i].Lot.Cost.Number.Cmp(&matches[j].Lot.Cost.Number) > 0
+		})
+	}
+
+	var reductions []Position
+	remaining := wanted
+	for _, p := range matches {
+		if remaining.Number.IsZero() {
+			break
+		}
+		take := p.Units.Abs()
+		if take.Number.Cmp(&remaining.Number) > 0 {
+			take = remaining
+		}
+		remaining = remaining.MustAdd(take.Neg())
+		if units.Number.Negative {
+			take = take.Neg()
+		}
+		lot := *p.Lot
+		reductions = append(reductions, Position{Units: take, Lot: &lot})
+	}
+	if !remaining.Number.IsZero() {
+		return nil, fmt.Errorf("not enough units to reduce %v %v: short by %v", units, spec, remaining)
+	}
+	inv.Positions = lots.Positions
+	return reductions, nil
+}
+
+// addPostingAtCost adds a (booked) Posting to the Inventory
+func (inv *Inventory) addPostingAtCost(p Posting, booking Booking) error {
+	if p.Cost == nil {
+		inv.Add(*p.Amount, nil)
+		return nil
+	}
+	if booking == BookingAverage && inv.isReduction(*p.Amount) {
+		if err := inv.average(p.Amount.Ccy); err != nil {
+			return fmt.Errorf("in addPostingAtCost: %w", err)
+		}
+	}
+	lot, err := newLot(*p.Amount, *p.Cost, p.Transaction.Date)
+	if err != nil {
+		return fmt.Errorf("in addPostingAtCost: %w", err)
+	}
+	inv.Add(*p.Amount, &lot)
+	return nil
+}
+
+// getInventories returns the Inventory of each account
+// at the start of date. Postings must already be booked and sorted.
+func getInventories(postings []Posting, atl AccountTimeLine, def Booking, date time.Time) (AccInv, error) {
+	invs := make(AccInv, 20)
+	for _, p := range postings {
+		if !p.Transaction.Date.Before(date) {
+			break
+		}
+		acc := p.Account.Name
+		if invs[acc] == nil {
+			invs[acc] = &Inventory{}
+		}
+		if err := invs[acc].addPostingAtCost(p, accountBooking(atl, acc, def)); err != nil {
+			return nil, fmt.Errorf("in getInventories: %w", err)
+		}
+	}
+	return invs, nil
+}
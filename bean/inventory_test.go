@@ -0,0 +1,111 @@
+package bean
+
+import (
+	"testing"
+	"time"
+)
+
+func testLot(cost string, month time.Month) *Lot {
+	return &Lot{
+		Cost: MustNewAmount(cost, "USD"),
+		Date: time.Date(2023, month, 1, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+func Test_Inventory_Add(t *testing.T) {
+	inv := Inventory{}
+	inv.Add(MustNewAmount("10", "GOO"), testLot("100", time.January))
+	inv.Add(MustNewAmount("5", "GOO"), testLot("100", time.January))
+	inv.Add(MustNewAmount("5", "GOO"), testLot("120", time.January))
+	inv.Add(MustNewAmount("50", "USD"), nil)
+
+	// same lots should be merged
+	if len(inv.Positions) != 3 {
+		t.Fatalf("want 3 positions, got %d: %v", len(inv.Positions), inv.String())
+	}
+	units := inv.Units()
+	if !units["GOO"].Eq(MustNewAmount("20", "GOO")) || !units["USD"].Eq(MustNewAmount("50", "USD")) {
+		t.Errorf("wrong units: %v", units)
+	}
+
+	// empty positions should be removed
+	inv.Add(MustNewAmount("-15", "GOO"), testLot("100", time.January))
+	inv.Add(MustNewAmount("-5", "GOO"), testLot("120", time.January))
+	inv.Add(MustNewAmount("-50", "USD"), nil)
+	if !inv.IsEmpty() {
+		t.Errorf("inventory should be empty: %v", inv.String())
+	}
+}
+
+func Test_Inventory_reduce(t *testing.T) {
+	newInv := func() *Inventory {
+		inv := &Inventory{}
+		inv.Add(MustNewAmount("10", "GOO"), testLot("100", time.January))
+		inv.Add(MustNewAmount("10", "GOO"), testLot("130", time.February))
+		inv.Add(MustNewAmount("10", "GOO"), testLot("110", time.March))
+		return inv
+	}
+	sell := MustNewAmount("-15", "GOO")
+	cases := []struct {
+		booking Booking
+		want    []string
+	}{
+		{BookingFIFO, []string{"-10 GOO {100 USD, 2023-01-01}", "-5 GOO {130 USD, 2023-02-01}"}},
+		{BookingLIFO, []string{"-10 GOO {110 USD, 2023-03-01}", "-5 GOO {130 USD, 2023-02-01}"}},
+		{BookingHIFO, []string{"-10 GOO {130 USD, 2023-02-01}", "-5 GOO {110 USD, 2023-03-01}"}},
+		{BookingAverage, []string{"-15 GOO {113.333333333333333333333 USD, 2023-01-01}"}},
+	}
+	for _, c := range cases {
+		got, err := newInv().reduce(sell, Cost{}, c.booking)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(c.want) {
+			t.Fatalf("%s: want %v, got %v", c.booking, c.want, got)
+		}
+		for i, p := range got {
+			if p.String() != c.want[i] {
+				t.Errorf("%s: want %s, got %s", c.booking, c.want[i], p)
+			}
+		}
+	}
+
+	// strict should fail if ambiguous
+	_, err := newInv().reduce(sell, Cost{}, BookingStrict)
+	if err == nil {
+		t.Error("ambiguous strict reduction should error")
+	}
+
+	// strict should succeed if the cost matches one lot
+	cost := MustNewAmount("130", "USD")
+	got, err := newInv().reduce(MustNewAmount("-5", "GOO"), Cost{Amount: &cost}, BookingStrict)
+	if err != nil || len(got) != 1 || !got[0].Lot.Cost.Eq(cost) {
+		t.Errorf("strict reduction with cost should match one lot: %v %v", got, err)
+	}
+
+	// strict should succeed if reducing every lot
+	_, err = newInv().reduce(MustNewAmount("-30", "GOO"), Cost{}, BookingStrict)
+	if err != nil {
+		t.Error(err)
+	}
+
+	// reducing too many units should fail
+	_, err = newInv().reduce(MustNewAmount("-31", "GOO"), Cost{}, BookingFIFO)
+	if err == nil {
+		t.Error("reducing too many units should error")
+	}
+
+	// reducing with no matching lots should fail
+	label := Cost{Label: "nope"}
+	_, err = newInv().reduce(sell, label, BookingFIFO)
+	if err == nil {
+		t.Error("reduction without matching lots should error")
+	}
+
+	// a failed average reduction should leave the lots unmerged
+	inv := newInv()
+	_, err = inv.reduce(MustNewAmount("-31", "GOO"), Cost{}, BookingAverage)
+	if err == nil || len(inv.Positions) != 3 {
+		t.Errorf("failed average reduction should not change the inventory: %v %v", inv, err)
+	}
+}
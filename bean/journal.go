@@ -0,0 +1,180 @@
+package bean
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// JournalEntry is one Posting to the account of a Journal,
+// with the account balance after it
+type JournalEntry struct {
+	Date      time.Time     `json:"date"`
+	Flag      string        `json:"flag"`
+	Payee     string        `json:"payee,omitempty"`
+	Narration string        `json:"narration,omitempty"`
+	Account   AccountName   `json:"account"` // the account (or sub-account) posted to
+	Amount    Amount        `json:"amount"`  // units of the Posting
+	Counter   []AccountName `json:"counter"` // the other accounts in the Transaction
+	Balance   CcyAmount     `json:"balance"` // running balance after the Posting
+	Pos       Pos           `json:"-"`
+}
+
+// Description returns the payee and narration separated by |,
+// or just the one that is set
+func (e JournalEntry) Description() string {
+	if e.Payee == "" || e.Narration == "" {
+		return e.Payee + e.Narration
+	}
+	return e.Payee + " | " + e.Narration
+}
+
+// Journal is every Posting to an account between two dates, in date order
+type Journal struct {
+	Account     AccountName    `json:"account"`
+	SubAccounts bool           `json:"sub_accounts"`
+	From        time.Time      `json:"from"`
+	To          time.Time      `json:"to"`
+	Opening     CcyAmount      `json:"opening"` // balance before From
+	Entries     []JournalEntry `json:"entries"`
+	Closing     CcyAmount      `json:"closing"` // balance after the last entry
+}
+
+// Journal returns the Postings to account from (inclusive) to (exclusive),
+// with a running balance in each currency. A zero from starts at the
+// first Posting and a zero to runs to the last. If subAccounts is true,
+// Postings to accounts below account are included too.
+func (l *Ledger) Journal(account AccountName, from time.Time, to time.Time, subAccounts bool) (*Journal, error) {
+	matches := func(name AccountName) bool {
+		if subAccounts {
+			return isSubAccount(name, account)
+		}
+		return name == account
+	}
+	known := false
+	for name := range l.AccountTimeLine {
+		known = known || matches(name)
+	}
+	if !known {
+		return nil, fmt.Errorf("account %s was never opened", account)
+	}
+
+	j := &Journal{Account: account, SubAccounts: subAccounts, From: from, To: to, Opening: CcyAmount{}, Entries: []JournalEntry{}}
+	bal := CcyAmount{}
+	for _, p := range l.Postings {
+		tx := p.Transaction
+		if !to.IsZero() && !tx.Date.Before(to) {
+			break
+		}
+		if !matches(p.Account.Name) || p.Amount == nil {
+			continue
+		}
+		addToCcyAmount(bal, *p.Amount)
+		if tx.Date.Before(from) {
+			addToCcyAmount(j.Opening, *p.Amount)
+			continue
+		}
+		entry := JournalEntry{
+			Date:      tx.Date,
+			Flag:      tx.Type,
+			Payee:     tx.Payee,
+			Narration: tx.Narration,
+			Account:   p.Account.Name,
+			Amount:    *p.Amount,
+			Balance:   make(CcyAmount, len(bal)),
+			Pos:       tx.Pos,
+		}
+		if entry.Flag == "txn" {
+			entry.Flag = "*"
+		}
+		for _, other := range tx.Postings {
+			name := other.Account.Name
+			if matches(name) || containsAccount(entry.Counter, name) {
+				continue
+			}
+			entry.Counter = append(entry.Counter, name)
+		}
+		for ccy, amt := range bal {
+			entry.Balance[ccy] = amt
+		}
+		j.Entries = append(j.Entries, entry)
+	}
+	j.Closing = bal
+	return j, nil
+}
+
+// containsAccount returns true if name is in names
+func containsAccount(names []AccountName, name AccountName) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// PrintJournal writes the Journal as a register with one line per entry
+// (and a line for each extra currency in the balance):
+// date, flag, description, counter-accounts, amount and running balance.
+// The posted account is shown before the counter-accounts if the
+// Journal includes sub-accounts.
+func PrintJournal(w io.Writer, j *Journal) error {
+	type row struct {
+		text   string
+		amount string
+		bals   []string
+	}
+	balLines := func(ca CcyAmount) []string {
+		var lines []string
+		for _, ccy := range sortedCcys(ca) {
+			amt := ca[ccy]
+			lines = append(lines, amt.Number.Text('f')+" "+string(ccy))
+		}
+		return lines
+	}
+	var rows []row
+	if !j.From.IsZero() {
+		rows = append(rows, row{text: j.From.Format(time.DateOnly) + "   Opening balance", bals: balLines(j.Opening)})
+	}
+	for _, e := range j.Entries {
+		counter := make([]string, len(e.Counter))
+		for i, name := range e.Counter {
+			counter[i] = string(name)
+		}
+		text := fmt.Sprintf("%s %s %s", e.Date.Format(time.DateOnly), e.Flag, e.Description())
+		if j.SubAccounts {
+			text += "  " + string(e.Account)
+		}
+		if len(counter) > 0 {
+			text += "  -> " + strings.Join(counter, ", ")
+		}
+		rows = append(rows, row{text: text, amount: e.Amount.String(), bals: balLines(e.Balance)})
+	}
+
+	textWidth, amountWidth, balWidth := 0, 0, 0
+	for _, r := range rows {
+		textWidth = max(textWidth, len([]rune(r.text)))
+		amountWidth = max(amountWidth, len(r.amount))
+		for _, b := range r.bals {
+			balWidth = max(balWidth, len(b))
+		}
+	}
+	for _, r := range rows {
+		if len(r.bals) == 0 {
+			r.bals = []string{""}
+		}
+		for i, b := range r.bals {
+			text, amount := r.text, r.amount
+			if i > 0 {
+				text, amount = "", ""
+			}
+			pad := strings.Repeat(" ", textWidth-len([]rune(text)))
+			line := fmt.Sprintf("%s%s  %*s  %*s", text, pad, amountWidth, amount, balWidth, b)
+			if _, err := fmt.Fprintln(w, strings.TrimRight(line, " ")); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
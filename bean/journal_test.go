@@ -0,0 +1,86 @@
+package bean
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestJournal(t *testing.T) {
+	l := loadText(t, `
+2023-01-01 open Assets:Bank
+2023-01-01 open Assets:Bank:Savings
+2023-01-01 open Income:Job
+2023-01-01 open Expenses:Food
+2023-01-01 open Expenses:Travel
+
+2023-01-02 * "Employer" "Salary"
+  Assets:Bank  1000 GBP
+  Income:Job
+
+2023-01-03 * "Saving"
+  Assets:Bank          -200 GBP
+  Assets:Bank:Savings   200 GBP
+
+2023-01-04 * "Trip"
+  Assets:Bank     -50 USD @ 0.8 GBP
+  Expenses:Travel  -10 GBP
+  Expenses:Food     50 GBP
+
+2023-01-05 * "Lunch"
+  Expenses:Food   5 GBP
+  Assets:Bank
+`)
+	j, err := l.Journal("Assets:Bank", time.Time{}, time.Time{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	if err := PrintJournal(&b, j); err != nil {
+		t.Fatal(err)
+	}
+	want := `2023-01-02 * Employer | Salary  -> Income:Job         1000 GBP  1000 GBP
+2023-01-03 * Saving  -> Assets:Bank:Savings           -200 GBP   800 GBP
+2023-01-04 * Trip  -> Expenses:Travel, Expenses:Food   -50 USD   800 GBP
+                                                                 -50 USD
+2023-01-05 * Lunch  -> Expenses:Food                    -5 GBP   795 GBP
+                                                                 -50 USD
+`
+	if diff := cmp.Diff(want, b.String()); diff != "" {
+		t.Error(diff)
+	}
+
+	// sub-accounts should be included between the dates
+	j, err = l.Journal("Assets:Bank", time.Date(2023, time.January, 3, 0, 0, 0, 0, time.UTC), time.Date(2023, time.January, 5, 0, 0, 0, 0, time.UTC), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range j.Entries {
+		got = append(got, string(e.Account)+" "+e.Amount.String())
+	}
+	wantEntries := []string{
+		"Assets:Bank -200 GBP",
+		"Assets:Bank:Savings 200 GBP",
+		"Assets:Bank -50 USD",
+	}
+	if diff := cmp.Diff(wantEntries, got); diff != "" {
+		t.Error(diff)
+	}
+	if len(j.Entries[0].Counter) != 0 {
+		t.Errorf("sub-accounts should not be counter-accounts: %v", j.Entries[0].Counter)
+	}
+	if got := j.Opening["GBP"]; got.String() != "1000 GBP" {
+		t.Errorf("Opening = %v, want 1000 GBP", got)
+	}
+	if got := j.Closing["GBP"]; got.String() != "1000 GBP" {
+		t.Errorf("Closing = %v, want 1000 GBP", got)
+	}
+
+	// unknown accounts should error
+	if _, err := l.Journal("Assets:Nope", time.Time{}, time.Time{}, false); err == nil {
+		t.Error("Journal() expected an error for an unknown account")
+	}
+}
@@ -1,6 +1,7 @@
 package bean
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -16,11 +17,16 @@ func init() {
 }
 
 // debug indicates whether DEBUG env var is set to 1
+// Per-posting code checks it before logging, as the log arguments
+// are allocated even when the output is discarded.
 var debug bool
 
 // apd Decimal context
 var apdCtx = apd.BaseContext
 
+// apd Decimal context for division, which needs a precision
+var apdQuoCtx = apd.BaseContext.WithPrecision(24)
+
 // Ledger is the full view of the beancount file
 type Ledger struct {
 	AccountEvents   []AccountEvent
@@ -29,7 +35,18 @@ type Ledger struct {
 	Transactions    []Transaction
 	Postings        []Posting
 	Prices          []Price
+	PriceMap        *PriceMap // built from Prices and Transactions
 	Pads            []Pad
+	Notes           []Note
+	Documents       []Document
+	Events          []Event
+	Commodities     []Commodity
+	Queries         []Query
+	Customs         []Custom
+	Disposals       []Disposal
+	Options         Options
+	Warnings        []error
+	Files           []string // in the order they were loaded
 }
 
 // NewLedger parses the supplied file and creates a ledger
@@ -40,83 +57,223 @@ func NewLedger(dbg bool) *Ledger {
 		log.SetOutput(os.Stderr)
 	}
 
-	l := Ledger{}
+	l := Ledger{Options: defaultOptions()}
 	return &l
 }
 
-// Load a beancount file/string into the Ledger
+// Load a beancount file/string into the Ledger.
+// Any included files are relative to the working directory.
+// All errors found are returned joined together.
 func (l *Ledger) Load(rc io.ReadCloser) (*Ledger, error) {
-	var err error
-	l, err = l.parse(rc)
-	if err != nil {
-		return l, fmt.Errorf("in GetBalances: %w", err)
-	}
-	l.Transactions, err = balanceTransactions(l.Transactions)
-	if err != nil {
-		return l, fmt.Errorf("in GetBalances: %w", err)
+	res := l.LoadAll(rc)
+	return res.Ledger, res.Err()
+}
+
+// LoadFile loads the beancount file at path into the Ledger,
+// along with any files it includes (relative to path)
+func (l *Ledger) LoadFile(path string) (*Ledger, error) {
+	res := l.LoadFileAll(path)
+	return res.Ledger, res.Err()
+}
+
+// LoadAll is like Load, but keeps going past invalid entries
+// and returns every Error (and warning) found
+func (l *Ledger) LoadAll(rc io.ReadCloser) LoadResult {
+	directives, err := l.parse(rc)
+	return newLoadResult(l, directives, errors.Join(err, l.build()))
+}
+
+// LoadFileAll is like LoadFile, but keeps going past invalid entries
+// and returns every Error (and warning) found
+func (l *Ledger) LoadFileAll(path string) LoadResult {
+	directives, err := l.parseFile(path)
+	return newLoadResult(l, directives, errors.Join(err, l.build()))
+}
+
+// build does the booking, balancing and validation of
+// the parsed entries, and extracts the Postings.
+// Transactions that cannot be booked or balanced are dropped,
+// and all the errors are returned.
+func (l *Ledger) build() error {
+	errs := []error{checkAccountNames(l)}
+	if l.Options.AutoOpen {
+		l.AccountEvents = append(l.AccountEvents, autoOpens(l)...)
 	}
+	// NewAccountTimeLine never errors currently
+	l.AccountTimeLine, _ = NewAccountTimeLine(l.AccountEvents)
+
+	var err error
+	l.Transactions, l.Disposals, err = bookTransactions(l.Transactions, l.AccountTimeLine, l.Options.BookingMethod)
+	l.Transactions, l.Disposals = dropTransactions(l.Transactions, l.Disposals, err)
+	errs = append(errs, err)
+	l.Transactions, err = balanceTransactions(l.Transactions, l.Options)
+	l.Transactions, l.Disposals = dropTransactions(l.Transactions, l.Disposals, err)
+	errs = append(errs, err)
+	errs = append(errs, checkCurrencies(l.Transactions, l.AccountTimeLine))
+	errs = append(errs, checkGains(l.Transactions, l.Disposals, l.Options))
 	debugSlice(l.Transactions, "ledger.Transactions")
 
 	// extractPostings never errors currently
 	postings, _ := extractPostings(l.Transactions)
 	postings, _ = sortPostings(postings)
+
+	padTxs, err := padTransactions(l.Pads, l.Balances, postings)
+	errs = append(errs, err)
+	if len(padTxs) > 0 {
+		l.Transactions = append(l.Transactions, padTxs...)
+		postings, _ = extractPostings(l.Transactions)
+		postings, _ = sortPostings(postings)
+	}
 	l.Postings = postings
 	debugSlice(l.Postings, "ledger.Postings")
 
-	accountTimeLine, err := NewAccountTimeLine(l.AccountEvents)
-	l.AccountTimeLine = accountTimeLine
+	l.PriceMap = NewPriceMap(l.Prices, l.Transactions)
+	errs = append(errs, checkLifecycle(l))
+	errs = append(errs, checkBalances(l.Balances, l.Postings, l.Options))
+	return errors.Join(errs...)
+}
 
-	return l, nil
+// dropTransactions removes the Transactions at the positions of the
+// errors in err, along with their Disposals
+func dropTransactions(transactions []Transaction, disposals []Disposal, err error) ([]Transaction, []Disposal) {
+	bad := make(map[Pos]bool)
+	for _, e := range collectErrors(err) {
+		bad[e.Pos] = true
+	}
+	if len(bad) == 0 {
+		return transactions, disposals
+	}
+	index := make([]int, len(transactions))
+	kept := make([]Transaction, 0, len(transactions))
+	for i, tx := range transactions {
+		if bad[tx.Pos] {
+			log.Println("dropTransaction", tx.Pos)
+			index[i] = -1
+			continue
+		}
+		index[i] = len(kept)
+		kept = append(kept, tx)
+	}
+	var keptDisposals []Disposal
+	for _, d := range disposals {
+		if index[d.txIndex] >= 0 {
+			d.txIndex = index[d.txIndex]
+			keptDisposals = append(keptDisposals, d)
+		}
+	}
+	return kept, keptDisposals
 }
 
 // newLedger creates the basic ledger with
 // accountEvents (open/close), balance directives and transactions.
-// These are not yet logically validated, only checked semantically
+// These are not yet logically validated, only checked semantically.
+// Invalid directives are skipped and all the errors are returned.
 func (l *Ledger) fill(directives []Directive) (*Ledger, error) {
+	var errs []error
 	var accountEvents []AccountEvent
 	var balances []Balance
 	var transactions []Transaction
 	var prices []Price
 	var pads []Pad
+	var notes []Note
+	var documents []Document
+	var events []Event
+	var commodities []Commodity
+	var queries []Query
+	var customs []Custom
+	// pushed tags and metadata only apply within a single file
+	stacks := map[string]*pushStack{}
+	var files []string
 
-	for _, directive := range directives {
+	for i, directive := range directives {
 		if len(directive.Lines) == 0 {
 			continue
 		}
-		switch typeStr := dirType(directive.Lines[0].Tokens[1].Text); typeStr {
-		case dirBalance:
-			d, err := newBalance(directive)
-			if err != nil {
-				return l, fmt.Errorf("in newLedger: %w", err)
+		stack, ok := stacks[directive.File]
+		if !ok {
+			stack = &pushStack{}
+			stacks[directive.File] = stack
+			files = append(files, directive.File)
+		}
+		switch dirType(directive.Lines[0].Tokens[0].Text) {
+		case dirOption:
+			if err := l.setOption(directive); err != nil {
+				errs = append(errs, directiveError(directive, err))
 			}
-			balances = append(balances, d)
-		case dirOpen, dirClose:
-			d, err := newAccountEvent(directive)
-			if err != nil {
-				return l, fmt.Errorf("in newLedger: %w", err)
+			continue
+		case dirPlugin:
+			if err := l.setPlugin(directive); err != nil {
+				errs = append(errs, directiveError(directive, err))
 			}
-			accountEvents = append(accountEvents, d)
-		case dirTxn, dirStar, dirBang:
+			continue
+		case dirPushtag, dirPoptag, dirPushmeta, dirPopmeta:
+			if err := stack.update(directive); err != nil {
+				errs = append(errs, directiveError(directive, err))
+			}
+			continue
+		}
+		// Transactions are by far the most common, so they are
+		// made directly instead of being boxed in an Entry
+		if isTransaction(directive) {
 			d, err := newTransaction(directive)
 			if err != nil {
-				return l, fmt.Errorf("in newLedger: %w", err)
+				errs = append(errs, directiveError(directive, err))
+				continue
 			}
-			transactions = append(transactions, d)
-		case dirPrice:
-			d, err := newPrice(directive)
-			if err != nil {
-				return l, fmt.Errorf("in newLedger: %w", err)
+			d.Tags = stack.applyTags(d.Tags)
+			d.Meta = stack.applyMeta(d.Meta)
+			if transactions == nil {
+				transactions = make([]Transaction, 0, len(directives)-i)
 			}
+			transactions = append(transactions, d)
+			continue
+		}
+		e, err := newEntry(directive)
+		if err != nil {
+			errs = append(errs, directiveError(directive, err))
+			continue
+		}
+		switch d := e.(type) {
+		case Balance:
+			d.Meta = stack.applyMeta(d.Meta)
+			balances = append(balances, d)
+		case AccountEvent:
+			d.Meta = stack.applyMeta(d.Meta)
+			accountEvents = append(accountEvents, d)
+		case Transaction:
+			d.Tags = stack.applyTags(d.Tags)
+			d.Meta = stack.applyMeta(d.Meta)
+			transactions = append(transactions, d)
+		case Price:
+			d.Meta = stack.applyMeta(d.Meta)
 			prices = append(prices, d)
-		case dirPad:
-			d, err := newPad(directive)
-			if err != nil {
-				return l, fmt.Errorf("in newLedger: %w", err)
-			}
+		case Pad:
+			d.Meta = stack.applyMeta(d.Meta)
 			pads = append(pads, d)
-		case dirNote, dirCommodity, dirQuery, dirCustom:
-		default:
-			return l, fmt.Errorf("in NewLedger: found unrecognised directive: %s", typeStr)
+		case Note:
+			d.Meta = stack.applyMeta(d.Meta)
+			notes = append(notes, d)
+		case Document:
+			d.Tags = stack.applyTags(d.Tags)
+			d.Meta = stack.applyMeta(d.Meta)
+			documents = append(documents, d)
+		case Event:
+			d.Meta = stack.applyMeta(d.Meta)
+			events = append(events, d)
+		case Commodity:
+			d.Meta = stack.applyMeta(d.Meta)
+			commodities = append(commodities, d)
+		case Query:
+			d.Meta = stack.applyMeta(d.Meta)
+			queries = append(queries, d)
+		case Custom:
+			d.Meta = stack.applyMeta(d.Meta)
+			customs = append(customs, d)
+		}
+	}
+	for _, file := range files {
+		if err := stacks[file].check(); err != nil {
+			errs = append(errs, err)
 		}
 	}
 	debugSlice(transactions, "transactions")
@@ -124,38 +281,123 @@ func (l *Ledger) fill(directives []Directive) (*Ledger, error) {
 	debugSlice(balances, "balances")
 	debugSlice(prices, "prices")
 	debugSlice(pads, "pads")
+	debugSlice(notes, "notes")
+	debugSlice(documents, "documents")
 	l.AccountEvents = accountEvents
 	l.Balances = balances
 	l.Transactions = transactions
 	l.Prices = prices
 	l.Pads = pads
-	return l, nil
-}
-
-// parse does all the work of loading a file path
-// and returning a Ledger
-func (l *Ledger) parse(rc io.ReadCloser) (*Ledger, error) {
-	// getTokens never errors currently
-	tokens, _ := getTokens(rc)
-	// makeLines never errors currently
-	lines, _ := makeLines(tokens)
-	debugSlice(lines, "lines")
-	directives, err := makeDirectives(lines)
-	if err != nil {
-		return &Ledger{}, fmt.Errorf("in parse: %w", err)
+	l.Notes = notes
+	l.Documents = documents
+	l.Events = events
+	l.Commodities = commodities
+	l.Queries = queries
+	l.Customs = customs
+	return l, errors.Join(errs...)
+}
+
+// isTransaction returns true if the Directive is a Transaction
+func isTransaction(directive Directive) bool {
+	tokens := directive.Lines[0].Tokens
+	if len(tokens) < 2 {
+		return false
+	}
+	switch dirType(tokens[1].Text) {
+	case dirTxn, dirStar, dirBang:
+		return true
+	}
+	return false
+}
+
+// newEntry creates the Entry for a dated Directive
+func newEntry(directive Directive) (Entry, error) {
+	if len(directive.Lines[0].Tokens) < 2 {
+		return nil, fmt.Errorf("incomplete directive")
+	}
+	switch typeStr := dirType(directive.Lines[0].Tokens[1].Text); typeStr {
+	case dirBalance:
+		return newBalance(directive)
+	case dirOpen, dirClose:
+		return newAccountEvent(directive)
+	case dirTxn, dirStar, dirBang:
+		return newTransaction(directive)
+	case dirPrice:
+		return newPrice(directive)
+	case dirPad:
+		return newPad(directive)
+	case dirNote:
+		return newNote(directive)
+	case dirDocument:
+		return newDocument(directive)
+	case dirEvent:
+		return newEvent(directive)
+	case dirCommodity:
+		return newCommodity(directive)
+	case dirQuery:
+		return newQuery(directive)
+	case dirCustom:
+		return newCustom(directive)
+	default:
+		return nil, fmt.Errorf("found unrecognised directive: %s", typeStr)
 	}
+}
+
+// parse reads all the Directives from a file/string into the Ledger.
+// It keeps going past errors, and returns the Directives read.
+func (l *Ledger) parse(rc io.ReadCloser) ([]Directive, error) {
+	directives, readErr := readDirectives(rc, "", nil)
 	debugSlice(directives, "directives")
+	l.Files = directiveFiles(directives)
+	_, err := l.fill(directives)
+	return directives, errors.Join(readErr, err)
+}
 
-	l, err = l.fill(directives)
-	if err != nil {
-		return l, fmt.Errorf("in parse: %w", err)
+// directiveFiles returns the files of the Directives in order.
+// Directives not loaded from a file are ignored.
+func directiveFiles(directives []Directive) []string {
+	var files []string
+	seen := make(map[string]bool)
+	for _, d := range directives {
+		if d.File != "" && !seen[d.File] {
+			seen[d.File] = true
+			files = append(files, d.File)
+		}
 	}
-	return l, nil
+	return files
 }
 
-// GetBalances returns the final balance of
-// all accounts, separately for each currency
+// parseFile does the same as parse for the file at path
+func (l *Ledger) parseFile(path string) ([]Directive, error) {
+	directives, readErr := readFileDirectives(path, nil)
+	debugSlice(directives, "directives")
+	l.Files = directiveFiles(directives)
+	_, err := l.fill(directives)
+	return directives, errors.Join(readErr, err)
+}
+
+// GetBalances returns the balance of all accounts at the
+// start of date, separately for each currency
 func (l *Ledger) GetBalances(date time.Time) (AccBal, error) {
 	accBalances, err := getBalances(l.Postings, l.AccountTimeLine, date)
 	return accBalances, err
 }
+
+// GetInventories returns the Inventory (with lots held at cost)
+// of all accounts at the start of date
+func (l *Ledger) GetInventories(date time.Time) (AccInv, error) {
+	return getInventories(l.Postings, l.AccountTimeLine, l.Options.BookingMethod, date)
+}
+
+// RealizedGains returns every sale from a lot held at cost,
+// with its gain or loss, grouped by tax year
+func (l *Ledger) RealizedGains() []GainsYear {
+	return getGainsYears(l.Disposals)
+}
+
+// GetBalanceChanges returns the change in all accounts between
+// start (inclusive) and end (exclusive), separately for each currency
+func (l *Ledger) GetBalanceChanges(start time.Time, end time.Time) (AccBal, error) {
+	accBalances, err := getBalanceChanges(l.Postings, l.AccountTimeLine, start, end)
+	return accBalances, err
+}
@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/cockroachdb/apd/v3"
 	"github.com/google/go-cmp/cmp"
 )
 
@@ -14,7 +15,7 @@ func TestNewLedger(t *testing.T) {
 	acc := "Assets:Bank"
 	num := "100"
 	ccy := "GBP"
-	directives := []Directive{{[]Line{
+	directives := []Directive{{Lines: []Line{
 		{Tokens: []Token{
 			{LineNum: 1, Text: "2023-01-01"},
 			{LineNum: 1, Text: "balance"},
@@ -24,30 +25,35 @@ func TestNewLedger(t *testing.T) {
 		}},
 	}}}
 	want := Ledger{
+		Options: defaultOptions(),
 		Balances: []Balance{{
 			Date:    time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC),
 			Account: Account{AccountName(acc)},
 			Amount:  MustNewAmount(num, ccy),
+			Pos:     Pos{Line: 1},
 		}},
 	}
 	got, _ := NewLedger(false).fill(directives)
 	comparer := cmp.Comparer(func(x, y Amount) bool {
 		return x.Eq(y)
 	})
-	if diff := cmp.Diff(&want, got, comparer); diff != "" {
+	decimals := cmp.Comparer(func(x, y apd.Decimal) bool {
+		return x.Cmp(&y) == 0
+	})
+	if diff := cmp.Diff(&want, got, comparer, decimals); diff != "" {
 		t.Error(diff)
 	}
 
 	// empty directive should be ignored
-	directives = []Directive{{[]Line{}}}
-	want = Ledger{}
+	directives = []Directive{{Lines: []Line{}}}
+	want = Ledger{Options: defaultOptions()}
 	got, _ = NewLedger(false).fill(directives)
-	if diff := cmp.Diff(&want, got); diff != "" {
+	if diff := cmp.Diff(&want, got, decimals); diff != "" {
 		t.Error(diff)
 	}
 
 	// invalid balance should raise
-	directives = []Directive{{[]Line{
+	directives = []Directive{{Lines: []Line{
 		{Tokens: []Token{
 			{LineNum: 1, Text: "xxx-01-01"},
 			{LineNum: 1, Text: "balance"},
@@ -63,7 +69,7 @@ func TestNewLedger(t *testing.T) {
 	}
 
 	// invalid account event should raise
-	directives = []Directive{{[]Line{
+	directives = []Directive{{Lines: []Line{
 		{Tokens: []Token{
 			{LineNum: 1, Text: "xxx-01-01"},
 			{LineNum: 1, Text: "open"},
@@ -79,7 +85,7 @@ func TestNewLedger(t *testing.T) {
 	}
 
 	// invalid transaction should raise
-	directives = []Directive{{[]Line{
+	directives = []Directive{{Lines: []Line{
 		{Tokens: []Token{
 			{LineNum: 1, Text: "xxx-01-01"},
 			{LineNum: 1, Text: "*"},
@@ -0,0 +1,343 @@
+package bean
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// TokenKind is the type of a Token
+type TokenKind uint8
+
+// Kinds of Token
+const (
+	TokenWord      TokenKind = iota // keywords and anything not matched below
+	TokenDate                       // 2023-01-01
+	TokenNumber                     // -12.50
+	TokenCurrency                   // GBP
+	TokenAccount                    // Assets:Bank
+	TokenString                     // "quoted", with escapes removed
+	TokenTag                        // #tag
+	TokenLink                       // ^link
+	TokenFlag                       // * or !
+	TokenKey                        // key: (metadata)
+	TokenBool                       // TRUE or FALSE
+	TokenLCurl                      // {
+	TokenRCurl                      // }
+	TokenLCurlCurl                  // {{
+	TokenRCurlCurl                  // }}
+	TokenAt                         // @
+	TokenAtAt                       // @@
+	TokenComma                      // ,
+	TokenTilde                      // ~
+	TokenComment                    // ; comment or * heading
+	TokenEOL                        // end of line
+)
+
+var tokenKindNames = [...]string{
+	"WORD", "DATE", "NUMBER", "CURRENCY", "ACCOUNT", "STRING", "TAG", "LINK", "FLAG", "KEY", "BOOL",
+	"{", "}", "{{", "}}", "@", "@@", ",", "~", "COMMENT", "EOL",
+}
+
+func (k TokenKind) String() string {
+	if int(k) < len(tokenKindNames) {
+		return tokenKindNames[k]
+	}
+	return fmt.Sprintf("TokenKind(%d)", int(k))
+}
+
+// getTokens reads everything from rc and splits it into Tokens.
+// An EOL Token ends every line, and an extra one is added at the end
+// to make subsequent funcs lives easier.
+// Unterminated strings are returned as errors, along with all the Tokens.
+func getTokens(rc io.ReadCloser) ([]Token, error) {
+	defer rc.Close()
+	// a Builder avoids copying the input again to make a string
+	var b strings.Builder
+	if _, err := io.Copy(&b, rc); err != nil {
+		return nil, fmt.Errorf("in getTokens: %w", err)
+	}
+	tokens, err := lex(b.String())
+	debugTokens(tokens)
+	return tokens, err
+}
+
+// lex splits src into Tokens.
+// Token Text is a substring of src (no copying),
+// except for strings containing escapes or CRLFs.
+func lex(src string) ([]Token, error) {
+	src = strings.TrimPrefix(src, "\uFEFF")
+	// ledgers average about one token for every eight bytes
+	tokens := make([]Token, 0, len(src)/8+1)
+	var errs []error
+	var lineNum int32 = 1
+	lineStart := 0    // index of the first byte of the line
+	indented := false // whether the line started with whitespace
+	first := true     // whether no token has been added on this line
+
+	for i := 0; i < len(src); {
+		c := src[i]
+		t := Token{LineNum: lineNum, Column: int32(i - lineStart + 1)}
+		switch {
+		case c == '\n':
+			tokens = append(tokens, Token{LineNum: lineNum, Kind: TokenEOL})
+			i++
+			lineNum++
+			lineStart = i
+			indented = false
+			first = true
+			continue
+		case isSpace(c):
+			indented = indented || i == lineStart
+			for i++; i < len(src) && isSpace(src[i]); i++ {
+			}
+			continue
+		case c == ';' || (c == '*' && i == lineStart):
+			// * only counts as a comment if it's the first byte on a line
+			end := i + strings.IndexByte(src[i:], '\n')
+			if end < i {
+				end = len(src)
+			}
+			t.Kind = TokenComment
+			t.Text = strings.TrimRight(src[i:end], "\r")
+			i = end
+		case c == '"':
+			text, end, ok := lexString(src, i)
+			if !ok {
+				errs = append(errs, &Error{Pos: Pos{Line: int(lineNum)}, Column: int(t.Column), Message: "unterminated string"})
+			}
+			t.Kind = TokenString
+			t.Text = text
+			// strings may contain newlines
+			if n := strings.Count(src[i:end], "\n"); n > 0 {
+				lineNum += int32(n)
+				lineStart = i + strings.LastIndexByte(src[i:end], '\n') + 1
+			}
+			i = end
+		case c == '{' || c == '}' || c == '@':
+			// {{ }} and @@ are kept together
+			n := 1
+			if i+1 < len(src) && src[i+1] == c {
+				n = 2
+			}
+			t.Kind = punctKind(c, n)
+			t.Text = src[i : i+n]
+			i += n
+		case c == ',' || c == '~':
+			t.Kind = TokenComma
+			if c == '~' {
+				t.Kind = TokenTilde
+			}
+			t.Text = src[i : i+1]
+			i++
+		default:
+			end := i + 1
+			for end < len(src) && !isDelim(src[end]) {
+				end++
+			}
+			t.Text = src[i:end]
+			t.Kind = wordKind(t.Text)
+			i = end
+		}
+		t.Indent = first && indented
+		tokens = append(tokens, t)
+		first = false
+	}
+	tokens = append(tokens, Token{LineNum: lineNum, Kind: TokenEOL})
+	return tokens, errors.Join(errs...)
+}
+
+// isSpace returns true for whitespace other than newlines
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\f' || c == '\v'
+}
+
+// isDelim returns true for bytes that end an unquoted token
+func isDelim(c byte) bool {
+	switch c {
+	case ' ', '\t', '\r', '\f', '\v', '\n', '"', ';', '{', '}', '@', ',', '~':
+		return true
+	}
+	return false
+}
+
+// punctKind returns the TokenKind of n (1 or 2) punctuation bytes c
+func punctKind(c byte, n int) TokenKind {
+	switch c {
+	case '{':
+		if n == 2 {
+			return TokenLCurlCurl
+		}
+		return TokenLCurl
+	case '}':
+		if n == 2 {
+			return TokenRCurlCurl
+		}
+		return TokenRCurl
+	default:
+		if n == 2 {
+			return TokenAtAt
+		}
+		return TokenAt
+	}
+}
+
+// lexString returns the contents of the string starting with the quote at
+// src[start], and the index after the closing quote.
+// It returns false if the string is never closed.
+func lexString(src string, start int) (string, int, bool) {
+	i := start + 1
+	for {
+		n := strings.IndexByte(src[i:], '"')
+		if n < 0 {
+			return src[start+1:], len(src), false
+		}
+		i += n
+		// count the backslashes before the quote to see if it is escaped
+		backslashes := 0
+		for j := i - 1; j > start && src[j] == '\\'; j-- {
+			backslashes++
+		}
+		if backslashes%2 == 0 {
+			break
+		}
+		i++
+	}
+	text := src[start+1 : i]
+	if strings.IndexByte(text, '\\') >= 0 || strings.IndexByte(text, '\r') >= 0 {
+		text = unescapeString(text)
+	}
+	return text, i + 1, true
+}
+
+// unescapeString replaces escape sequences and removes
+// carriage returns from CRLF line endings
+func unescapeString(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s):
+			i++
+			switch s[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case '"', '\\':
+				b.WriteByte(s[i])
+			default:
+				b.WriteByte('\\')
+				b.WriteByte(s[i])
+			}
+		case c == '\r' && i+1 < len(s) && s[i+1] == '\n':
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// Quote returns s as a beancount string, the inverse of the lexer.
+// Only backslashes and double quotes are escaped. Everything else,
+// including newlines and non-ASCII characters, is written as it is.
+func Quote(s string) string {
+	var b strings.Builder
+	b.Grow(len(s) + 2)
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		if s[i] == '"' || s[i] == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(s[i])
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// wordKind returns the TokenKind of an unquoted word
+func wordKind(text string) TokenKind {
+	c := text[0]
+	switch {
+	case c == '#' && len(text) > 1:
+		return TokenTag
+	case c == '^' && len(text) > 1:
+		return TokenLink
+	case len(text) == 1 && strings.IndexByte("*!&#?%", c) >= 0:
+		return TokenFlag
+	case isDate(text):
+		return TokenDate
+	case isNumber(text):
+		return TokenNumber
+	case text == "TRUE" || text == "FALSE":
+		return TokenBool
+	case c >= 'a' && c <= 'z' && strings.IndexByte(text, ':') > 0:
+		return TokenKey
+	case (isUpper(c) || c >= 0x80) && strings.IndexByte(text, ':') > 0:
+		return TokenAccount
+	case isCurrency(text):
+		return TokenCurrency
+	}
+	return TokenWord
+}
+
+func isUpper(c byte) bool {
+	return c >= 'A' && c <= 'Z'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// isDate returns true for YYYY-MM-DD (or with slashes)
+func isDate(text string) bool {
+	if len(text) != 10 || (text[4] != '-' && text[4] != '/') || text[7] != text[4] {
+		return false
+	}
+	for _, i := range [...]int{0, 1, 2, 3, 5, 6, 8, 9} {
+		if !isDigit(text[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// isNumber returns true for an optionally signed decimal number
+func isNumber(text string) bool {
+	if text[0] == '-' || text[0] == '+' {
+		text = text[1:]
+	}
+	digits := 0
+	dots := 0
+	for i := 0; i < len(text); i++ {
+		switch {
+		case isDigit(text[i]):
+			digits++
+		case text[i] == '.':
+			dots++
+		default:
+			return false
+		}
+	}
+	return digits > 0 && dots <= 1
+}
+
+// isCurrency returns true for eg GBP, VWRL.L or BRK-B
+func isCurrency(text string) bool {
+	if !isUpper(text[0]) {
+		return false
+	}
+	last := text[len(text)-1]
+	if !isUpper(last) && !isDigit(last) {
+		return false
+	}
+	for i := 1; i < len(text); i++ {
+		c := text[i]
+		if !isUpper(c) && !isDigit(c) && strings.IndexByte("'._-", c) < 0 {
+			return false
+		}
+	}
+	return true
+}
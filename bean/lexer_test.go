@@ -0,0 +1,82 @@
+package bean
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_lex_kinds(t *testing.T) {
+	text := `2023-01-01 * "Shop" "Food" #trip ^receipt
+  receipt: TRUE
+  Assets:Invest  -1.5 VWRL.L {{150 USD, "lot"}} @@ 1.2 EUR
+  Expenses:Food
+2023-01-02 open Assets:Bank GBP,USD
+2023-01-03 balance Assets:Bank 1.00~0.01 GBP`
+	tokens, err := lex(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []TokenKind
+	for _, tok := range tokens {
+		got = append(got, tok.Kind)
+	}
+	want := []TokenKind{
+		TokenDate, TokenFlag, TokenString, TokenString, TokenTag, TokenLink, TokenEOL,
+		TokenKey, TokenBool, TokenEOL,
+		TokenAccount, TokenNumber, TokenCurrency, TokenLCurlCurl, TokenNumber, TokenCurrency,
+		TokenComma, TokenString, TokenRCurlCurl, TokenAtAt, TokenNumber, TokenCurrency, TokenEOL,
+		TokenAccount, TokenEOL,
+		TokenDate, TokenWord, TokenAccount, TokenCurrency, TokenComma, TokenCurrency, TokenEOL,
+		TokenDate, TokenWord, TokenAccount, TokenNumber, TokenTilde, TokenNumber, TokenCurrency, TokenEOL,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func Test_lex_strings(t *testing.T) {
+	// escapes should be removed, and newlines kept
+	tokens, err := lex("2023-01-01 note Assets:Bank \"say \\\"hi\\\"\\\\\nthere\" ; done\n2023-01-02")
+	if err != nil {
+		t.Fatal(err)
+	}
+	str := tokens[3]
+	if str.Kind != TokenString || str.Text != "say \"hi\"\\\nthere" {
+		t.Errorf("wrong string: %q", str.Text)
+	}
+	if comment := tokens[4]; comment.Kind != TokenComment || comment.Text != "; done" || comment.LineNum != 2 {
+		t.Errorf("wrong comment: %+v", comment)
+	}
+	// lines should be counted inside strings
+	if date := tokens[6]; date.LineNum != 3 || date.Column != 1 {
+		t.Errorf("wrong position after string: %+v", date)
+	}
+
+	// unterminated strings should error
+	if _, err := lex(`2023-01-01 * "oops`); err == nil {
+		t.Error("unterminated string should error")
+	}
+}
+
+func Test_lex_crlfBOM(t *testing.T) {
+	// a BOM and CRLF line endings should be ignored
+	tokens, err := lex("\uFEFF2023-01-01 * \"a\r\nb\"\r\n  Assets:Bank 1 GBP\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, tok := range tokens {
+		got = append(got, tok.Text)
+	}
+	want := []string{"2023-01-01", "*", "a\nb", "", "Assets:Bank", "1", "GBP", "", ""}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Error(diff)
+	}
+	if first := tokens[0]; first.Kind != TokenDate || first.Column != 1 {
+		t.Errorf("BOM should be skipped: %+v", first)
+	}
+	if posting := tokens[4]; !posting.Indent || posting.LineNum != 3 {
+		t.Errorf("wrong posting token: %+v", posting)
+	}
+}
@@ -0,0 +1,152 @@
+package bean
+
+import (
+	"errors"
+	"log"
+	"sort"
+	"time"
+)
+
+// accountRef is a use of an account by an entry
+type accountRef struct {
+	account AccountName
+	date    time.Time
+	pos     Pos
+	kind    string
+}
+
+// accountRefs returns every use of an account by the entries in the Ledger
+func accountRefs(l *Ledger) []accountRef {
+	refs := make([]accountRef, 0, len(l.Postings)+len(l.Balances)+2*len(l.Pads)+len(l.Notes)+len(l.Documents))
+	for _, tx := range l.Transactions {
+		if tx.Type == padTxType {
+			// already covered by the Pad
+			continue
+		}
+		for _, p := range tx.Postings {
+			refs = append(refs, accountRef{p.Account.Name, tx.Date, tx.Pos, "transaction"})
+		}
+	}
+	for _, b := range l.Balances {
+		refs = append(refs, accountRef{b.Account.Name, b.Date, b.Pos, "balance"})
+	}
+	for _, p := range l.Pads {
+		refs = append(refs, accountRef{p.PadTo.Name, p.Date, p.Pos, "pad"})
+		refs = append(refs, accountRef{p.PadFrom.Name, p.Date, p.Pos, "pad"})
+	}
+	for _, n := range l.Notes {
+		refs = append(refs, accountRef{n.Account.Name, n.Date, n.Pos, "note"})
+	}
+	for _, d := range l.Documents {
+		refs = append(refs, accountRef{d.Account.Name, d.Date, d.Pos, "document"})
+	}
+	return refs
+}
+
+// autoOpens returns an open AccountEvent for every account that is used
+// without ever being opened, on the date it is first used
+func autoOpens(l *Ledger) []AccountEvent {
+	opened := make(map[AccountName]bool, len(l.AccountEvents))
+	for _, ae := range l.AccountEvents {
+		opened[ae.Account.Name] = true
+	}
+	first := make(map[AccountName]accountRef)
+	var order []AccountName
+	for _, ref := range accountRefs(l) {
+		if opened[ref.account] {
+			continue
+		}
+		prev, ok := first[ref.account]
+		if !ok {
+			order = append(order, ref.account)
+		}
+		if !ok || ref.date.Before(prev.date) {
+			first[ref.account] = ref
+		}
+	}
+	events := make([]AccountEvent, 0, len(order))
+	for _, acc := range order {
+		ref := first[acc]
+		log.Println("autoOpen", acc, ref.date.Format(time.DateOnly))
+		events = append(events, AccountEvent{Date: ref.date, Open: true, Account: Account{acc}, Pos: ref.pos})
+	}
+	return events
+}
+
+// checkLifecycle checks that every account is opened once before it is
+// closed (at most once) with a zero balance, and that every entry only
+// uses accounts that are open on its date.
+// Postings must already be sorted.
+func checkLifecycle(l *Ledger) error {
+	var errs []error
+	accounts := make([]AccountName, 0, len(l.AccountTimeLine))
+	for acc := range l.AccountTimeLine {
+		accounts = append(accounts, acc)
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i] < accounts[j] })
+
+	var closes []AccountEvent
+	for _, acc := range accounts {
+		var opened, closed *AccountEvent
+		for _, ae := range l.AccountTimeLine[acc] {
+			ae := ae
+			switch {
+			case ae.Open && opened != nil:
+				errs = append(errs, errorAt(ae.Pos, "account %s opened twice (already opened at %v)", acc, opened.Pos))
+			case ae.Open:
+				opened = &ae
+			case opened == nil:
+				errs = append(errs, errorAt(ae.Pos, "account %s closed before it was opened", acc))
+			case closed != nil:
+				errs = append(errs, errorAt(ae.Pos, "account %s closed twice (already closed at %v)", acc, closed.Pos))
+			default:
+				closed = &ae
+				closes = append(closes, ae)
+			}
+		}
+	}
+
+	// balances include postings on the close date
+	sort.SliceStable(closes, func(i, j int) bool { return closes[i].Date.Before(closes[j].Date) })
+	running := make(AccBal, 20)
+	next := 0
+	for _, ae := range closes {
+		for ; next < len(l.Postings) && !l.Postings[next].Transaction.Date.After(ae.Date); next++ {
+			addPosting(running, l.Postings[next])
+		}
+		for _, ccy := range sortedCcys(running[ae.Account.Name]) {
+			if amt := running[ae.Account.Name][ccy]; !amt.Number.IsZero() {
+				errs = append(errs, errorAt(ae.Pos, "account %s closed with non-zero balance %v", ae.Account.Name, amt))
+			}
+		}
+	}
+
+	for _, ref := range accountRefs(l) {
+		if _, ok := openEvent(l.AccountTimeLine, ref.account, ref.date); ok {
+			continue
+		}
+		state := "never opened"
+		for _, ae := range l.AccountTimeLine[ref.account] {
+			if ae.Open {
+				state = "not yet open"
+				if !ae.Date.After(ref.date) {
+					state = "closed"
+				}
+				break
+			}
+		}
+		errs = append(errs, errorAt(ref.pos, "%s uses account %s which is %s on %s",
+			ref.kind, ref.account, state, ref.date.Format(time.DateOnly)))
+	}
+	return errors.Join(errs...)
+}
+
+// sortedCcys returns the currencies of ca in order
+func sortedCcys(ca CcyAmount) []Ccy {
+	ccys := make([]Ccy, 0, len(ca))
+	for ccy := range ca {
+		ccys = append(ccys, ccy)
+	}
+	sort.Slice(ccys, func(i, j int) bool { return ccys[i] < ccys[j] })
+	return ccys
+}
@@ -0,0 +1,176 @@
+package bean
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/apd/v3"
+)
+
+// MetaKind is the type of a MetaValue
+type MetaKind int
+
+// Types of metadata values
+const (
+	MetaString MetaKind = iota
+	MetaNumber
+	MetaDate
+	MetaAccount
+	MetaCurrency
+	MetaBool
+	MetaTag
+	MetaAmount
+)
+
+// MetaValue is a typed metadata value.
+// Only the field matching Kind is set:
+// Text for strings, accounts, currencies and tags.
+type MetaValue struct {
+	Kind   MetaKind
+	Text   string
+	Number apd.Decimal
+	Date   time.Time
+	Bool   bool
+	Amount Amount
+	Line   int // source line of the key: value, zero if not from a file
+}
+
+func (v MetaValue) String() string {
+	switch v.Kind {
+	case MetaNumber:
+		return v.Number.Text('f')
+	case MetaDate:
+		return v.Date.Format(time.DateOnly)
+	case MetaAccount, MetaCurrency:
+		return v.Text
+	case MetaBool:
+		if v.Bool {
+			return "TRUE"
+		}
+		return "FALSE"
+	case MetaTag:
+		return "#" + v.Text
+	case MetaAmount:
+		return v.Amount.String()
+	default:
+		return fmt.Sprintf("%q", v.Text)
+	}
+}
+
+// Meta is the key: value metadata attached to directives and postings
+type Meta map[string]MetaValue
+
+// Keys returns the keys in the order they were written,
+// followed by any without a source line sorted by key
+func (m Meta) Keys() []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := m[keys[i]].Line, m[keys[j]].Line
+		switch {
+		case a == b:
+			return keys[i] < keys[j]
+		case a == 0 || b == 0:
+			return b == 0
+		}
+		return a < b
+	})
+	return keys
+}
+
+// isMetaLine returns true if the (indented) line is key: value metadata
+func isMetaLine(line Line) bool {
+	return line.Tokens[0].Kind == TokenKey
+}
+
+// newMeta creates Meta from metadata lines.
+// It returns nil if there are no lines.
+func newMeta(lines []Line) (Meta, error) {
+	if len(lines) == 0 {
+		return nil, nil
+	}
+	meta := make(Meta, len(lines))
+	for _, line := range lines {
+		if err := meta.addLine(line); err != nil {
+			return nil, fmt.Errorf("in newMeta: %w", err)
+		}
+	}
+	return meta, nil
+}
+
+// addLine parses a key: value line and adds it to the Meta
+func (m Meta) addLine(line Line) error {
+	if debug {
+		log.Println("addLine", line.Tokens[0].Text)
+	}
+	tokens := line.Tokens
+	key, rest, ok := strings.Cut(tokens[0].Text, ":")
+	if !ok || key == "" {
+		return fmt.Errorf("metadata must be of the form key: value: %s", line)
+	}
+	valueTokens := tokens[1:]
+	if rest != "" {
+		// no space between key and value
+		first := tokens[0]
+		first.Text = rest
+		first.Kind = wordKind(rest)
+		valueTokens = append([]Token{first}, valueTokens...)
+	}
+	value, err := newMetaValue(valueTokens)
+	if err != nil {
+		return fmt.Errorf("in addLine: %w", err)
+	}
+	value.Line = line.LineNum()
+	m[key] = value
+	return nil
+}
+
+// newMetaValue creates a MetaValue from the tokens after the key
+func newMetaValue(tokens []Token) (MetaValue, error) {
+	if len(tokens) == 0 {
+		return MetaValue{Kind: MetaString}, nil
+	}
+	first := tokens[0]
+	if len(tokens) == 2 {
+		amount, err := NewAmount(first.Text, tokens[1].Text)
+		if err != nil {
+			return MetaValue{}, fmt.Errorf("in newMetaValue: %w", err)
+		}
+		return MetaValue{Kind: MetaAmount, Amount: amount}, nil
+	}
+	if len(tokens) > 2 {
+		return MetaValue{}, fmt.Errorf("too many metadata tokens: %v", tokens)
+	}
+
+	text := first.Text
+	switch first.Kind {
+	case TokenString:
+		return MetaValue{Kind: MetaString, Text: text}, nil
+	case TokenBool:
+		return MetaValue{Kind: MetaBool, Bool: text == "TRUE"}, nil
+	case TokenTag:
+		return MetaValue{Kind: MetaTag, Text: text[1:]}, nil
+	case TokenDate:
+		date, err := getDate(text)
+		if err != nil {
+			return MetaValue{}, fmt.Errorf("in newMetaValue: %w", err)
+		}
+		return MetaValue{Kind: MetaDate, Date: date}, nil
+	case TokenNumber:
+		num, _, err := apdCtx.NewFromString(text)
+		if err != nil {
+			return MetaValue{}, fmt.Errorf("in newMetaValue: %w", err)
+		}
+		return MetaValue{Kind: MetaNumber, Number: *num}, nil
+	case TokenAccount:
+		return MetaValue{Kind: MetaAccount, Text: text}, nil
+	case TokenCurrency:
+		return MetaValue{Kind: MetaCurrency, Text: text}, nil
+	}
+	return MetaValue{}, fmt.Errorf("invalid metadata value: %s", text)
+}
@@ -0,0 +1,80 @@
+package bean
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_newMetaValue(t *testing.T) {
+	cases := []struct {
+		tokens []Token
+		kind   MetaKind
+		str    string
+	}{
+		{[]Token{{Kind: TokenString, Text: "all"}}, MetaString, `"all"`},
+		{tokensOf("12.5"), MetaNumber, "12.5"},
+		{tokensOf("2023-01-01"), MetaDate, "2023-01-01"},
+		{tokensOf("Assets:Bank"), MetaAccount, "Assets:Bank"},
+		{tokensOf("GBP"), MetaCurrency, "GBP"},
+		{tokensOf("TRUE"), MetaBool, "TRUE"},
+		{tokensOf("#trip"), MetaTag, "#trip"},
+		{tokensOf("10", "GBP"), MetaAmount, "10 GBP"},
+	}
+	for _, c := range cases {
+		got, err := newMetaValue(c.tokens)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Kind != c.kind || got.String() != c.str {
+			t.Errorf("want %d %s, got %d %s", c.kind, c.str, got.Kind, got)
+		}
+	}
+
+	// invalid values should error
+	for _, tokens := range [][]Token{tokensOf("foo"), tokensOf("1", "2", "3"), tokensOf("foo", "GBP")} {
+		if _, err := newMetaValue(tokens); err == nil {
+			t.Errorf("invalid metadata should error: %v", tokens)
+		}
+	}
+}
+
+func Test_newMeta(t *testing.T) {
+	lines := []Line{
+		{Tokens: tokensOf("portfolio:", "all")},
+		{Tokens: tokensOf("since:2023-01-01")},
+	}
+	lines[0].Tokens[1].Kind = TokenString
+	got, err := newMeta(lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got["portfolio"].Text != "all" || !got["since"].Date.Equal(time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("wrong meta: %v", got)
+	}
+
+	// keys should keep the order they were written in
+	lines = []Line{
+		{Tokens: []Token{{LineNum: 2, Kind: TokenKey, Text: "since:"}, {LineNum: 2, Kind: TokenDate, Text: "2023-01-01"}}},
+		{Tokens: []Token{{LineNum: 3, Kind: TokenKey, Text: "portfolio:"}, {LineNum: 3, Kind: TokenString, Text: "all"}}},
+	}
+	got, err = newMeta(lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got["pushed"] = MetaValue{Kind: MetaString, Text: "x"}
+	if diff := cmp.Diff([]string{"since", "portfolio", "pushed"}, got.Keys()); diff != "" {
+		t.Error(diff)
+	}
+
+	// no lines should give nil
+	if got, _ := newMeta(nil); got != nil {
+		t.Errorf("want nil, got %v", got)
+	}
+
+	// missing colon should error
+	if _, err := newMeta([]Line{{Tokens: tokensOf("portfolio")}}); err == nil {
+		t.Error("metadata without colon should error")
+	}
+}
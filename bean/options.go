@@ -0,0 +1,134 @@
+package bean
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/cockroachdb/apd/v3"
+)
+
+// Options are the settings from option directives
+type Options struct {
+	Title                       string
+	OperatingCurrencies         []Ccy
+	NameAssets                  string
+	NameLiabilities             string
+	NameEquity                  string
+	NameIncome                  string
+	NameExpenses                string
+	InferredToleranceDefault    map[Ccy]apd.Decimal // * applies to all currencies
+	InferredToleranceMultiplier apd.Decimal
+	BookingMethod               Booking
+	RenderCommas                bool
+	AutoOpen                    bool // open accounts on first use
+}
+
+// defaultOptions are the beancount defaults
+func defaultOptions() Options {
+	return Options{
+		NameAssets:                  "Assets",
+		NameLiabilities:             "Liabilities",
+		NameEquity:                  "Equity",
+		NameIncome:                  "Income",
+		NameExpenses:                "Expenses",
+		InferredToleranceDefault:    map[Ccy]apd.Decimal{},
+		InferredToleranceMultiplier: *apd.New(5, -1),
+		BookingMethod:               defaultBooking,
+	}
+}
+
+// RootNames returns the allowed root account names
+// in the order Assets, Liabilities, Equity, Income, Expenses
+func (o Options) RootNames() []string {
+	return []string{o.NameAssets, o.NameLiabilities, o.NameEquity, o.NameIncome, o.NameExpenses}
+}
+
+// set applies a single option.
+// It returns false if the option is not known.
+func (o *Options) set(name string, value string) (bool, error) {
+	switch name {
+	case "title":
+		o.Title = value
+	case "operating_currency":
+		o.OperatingCurrencies = append(o.OperatingCurrencies, Ccy(value))
+	case "name_assets":
+		o.NameAssets = value
+	case "name_liabilities":
+		o.NameLiabilities = value
+	case "name_equity":
+		o.NameEquity = value
+	case "name_income":
+		o.NameIncome = value
+	case "name_expenses":
+		o.NameExpenses = value
+	case "inferred_tolerance_default":
+		ccy, numStr, ok := strings.Cut(value, ":")
+		if !ok {
+			return true, fmt.Errorf("inferred_tolerance_default must be of the form CCY:NUMBER: %s", value)
+		}
+		num, _, err := apdCtx.NewFromString(numStr)
+		if err != nil {
+			return true, fmt.Errorf("in set: %w", err)
+		}
+		o.InferredToleranceDefault[Ccy(ccy)] = *num
+	case "inferred_tolerance_multiplier":
+		num, _, err := apdCtx.NewFromString(value)
+		if err != nil {
+			return true, fmt.Errorf("in set: %w", err)
+		}
+		o.InferredToleranceMultiplier = *num
+	case "booking_method":
+		booking, err := newBooking(value)
+		if err != nil {
+			return true, fmt.Errorf("in set: %w", err)
+		}
+		o.BookingMethod = booking
+	case "render_commas":
+		lower := strings.ToLower(value)
+		o.RenderCommas = lower == "true" || lower == "1"
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+// setOption applies an option Directive to the Ledger Options.
+// Unknown options are added to the Ledger Warnings.
+func (l *Ledger) setOption(directive Directive) error {
+	tokens := directive.Lines[0].Tokens
+	if len(tokens) != 3 || tokens[1].Kind != TokenString || tokens[2].Kind != TokenString {
+		return fmt.Errorf("option must have a quoted name and value")
+	}
+	name := tokens[1].Text
+	value := tokens[2].Text
+	log.Println("setOption", name, value)
+	known, err := l.Options.set(name, value)
+	if err != nil {
+		return fmt.Errorf("in setOption: %w", err)
+	}
+	if !known {
+		l.Warnings = append(l.Warnings, errorAt(directive.Pos(), "unknown option %s", name))
+	}
+	return nil
+}
+
+// autoAccountsPlugin is the beancount plugin that opens accounts on first use
+const autoAccountsPlugin = "beancount.plugins.auto_accounts"
+
+// setPlugin applies a plugin Directive.
+// Only auto_accounts is supported, others are added to the Ledger Warnings.
+func (l *Ledger) setPlugin(directive Directive) error {
+	tokens := directive.Lines[0].Tokens
+	if len(tokens) < 2 || len(tokens) > 3 || tokens[1].Kind != TokenString || (len(tokens) == 3 && tokens[2].Kind != TokenString) {
+		return fmt.Errorf("plugin must have a quoted name and optional config")
+	}
+	name := tokens[1].Text
+	log.Println("setPlugin", name)
+	if name == autoAccountsPlugin {
+		l.Options.AutoOpen = true
+		return nil
+	}
+	l.Warnings = append(l.Warnings, errorAt(directive.Pos(), "unsupported plugin %s", name))
+	return nil
+}
@@ -0,0 +1,64 @@
+package bean
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func optionDirective(name string, value string) Directive {
+	return Directive{Lines: []Line{{Tokens: []Token{
+		{LineNum: 1, Text: "option"},
+		{LineNum: 1, Kind: TokenString, Text: name},
+		{LineNum: 1, Kind: TokenString, Text: value},
+	}}}}
+}
+
+func Test_Ledger_setOption(t *testing.T) {
+	l := NewLedger(false)
+	directives := []Directive{
+		optionDirective("title", "My Books"),
+		optionDirective("operating_currency", "GBP"),
+		optionDirective("operating_currency", "USD"),
+		optionDirective("name_assets", "Actifs"),
+		optionDirective("inferred_tolerance_default", "*:0.005"),
+		optionDirective("booking_method", "FIFO"),
+		optionDirective("render_commas", "TRUE"),
+	}
+	for _, d := range directives {
+		if err := l.setOption(d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got := l.Options
+	if got.Title != "My Books" || got.NameAssets != "Actifs" || got.BookingMethod != BookingFIFO || !got.RenderCommas {
+		t.Errorf("options not set: %+v", got)
+	}
+	if diff := cmp.Diff([]Ccy{"GBP", "USD"}, got.OperatingCurrencies); diff != "" {
+		t.Error(diff)
+	}
+	if tol := got.InferredToleranceDefault["*"]; tol.String() != "0.005" {
+		t.Errorf("want tolerance 0.005, got %s", tol.String())
+	}
+	if len(l.Warnings) != 0 {
+		t.Errorf("known options should not warn: %v", l.Warnings)
+	}
+
+	// unknown options should warn
+	if err := l.setOption(optionDirective("foo", "bar")); err != nil {
+		t.Error(err)
+	}
+	if len(l.Warnings) != 1 {
+		t.Error("unknown option should warn")
+	}
+
+	// invalid values should error
+	for _, d := range []Directive{
+		optionDirective("booking_method", "RANDOM"),
+		optionDirective("inferred_tolerance_default", "0.005"),
+	} {
+		if err := l.setOption(d); err == nil {
+			t.Errorf("invalid option should error: %v", d)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package bean
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+// padTxType is the Transaction.Type used for synthesized padding transactions
+const padTxType = "P"
+
+// padTransactions resolves each Pad against the next Balance of each currency
+// in the padded account, and creates a padding Transaction (flag P) from
+// PadFrom for any currency that needs it.
+// A Pad applies until the next Pad of the same account.
+// Pads that end up creating no Transactions are returned as errors.
+func padTransactions(pads []Pad, balances []Balance, postings []Posting) ([]Transaction, error) {
+	sortedPads := make([]Pad, len(pads))
+	copy(sortedPads, pads)
+	sort.SliceStable(sortedPads, func(i, j int) bool {
+		return sortedPads[i].Date.Before(sortedPads[j].Date)
+	})
+	sortedBals := make([]Balance, len(balances))
+	copy(sortedBals, balances)
+	sort.SliceStable(sortedBals, func(i, j int) bool {
+		return sortedBals[i].Date.Before(sortedBals[j].Date)
+	})
+
+	var transactions []Transaction
+	var padPostings []Posting
+	var errs []error
+	for i, pad := range sortedPads {
+		account := pad.PadTo.Name
+		var until time.Time
+		for _, other := range sortedPads[i+1:] {
+			if other.PadTo.Name == account {
+				until = other.Date
+				break
+			}
+		}
+
+		padded := make(map[Ccy]bool, 3)
+		used := false
+		for _, b := range sortedBals {
+			if b.Account.Name != account || !b.Date.After(pad.Date) || padded[b.Amount.Ccy] {
+				continue
+			}
+			if !until.IsZero() && b.Date.After(until) {
+				break
+			}
+			padded[b.Amount.Ccy] = true
+
+			actual := balanceAtDate(postings, account, b.Amount.Ccy, b.Date)
+			actual = actual.MustAdd(balanceAtDate(padPostings, account, b.Amount.Ccy, b.Date))
+			diff := b.Amount.MustAdd(actual.Neg())
+			if diff.Number.IsZero() {
+				continue
+			}
+			used = true
+
+			neg := diff.Neg()
+			tx := Transaction{
+				Date:      pad.Date,
+				Type:      padTxType,
+				Narration: fmt.Sprintf("(Padding inserted for Balance of %v for difference %v)", b.Amount, diff),
+				Postings: []Posting{
+					{Account: pad.PadTo, Amount: &diff},
+					{Account: pad.PadFrom, Amount: &neg},
+				},
+				Pos: pad.Pos,
+			}
+			log.Println("padTransaction", tx)
+			transactions = append(transactions, tx)
+			for _, p := range tx.Postings {
+				p.Transaction = &tx
+				padPostings = append(padPostings, p)
+			}
+		}
+		if !used {
+			errs = append(errs, errorAt(pad.Pos, "unused pad for %s on %s", account, pad.Date.Format(time.DateOnly)))
+		}
+	}
+	return transactions, errors.Join(errs...)
+}
+
+// balanceAtDate sums the postings in ccy to account (and its sub-accounts)
+// from before the start of date
+func balanceAtDate(postings []Posting, account AccountName, ccy Ccy, date time.Time) Amount {
+	total := Amount{Ccy: ccy}
+	for _, p := range postings {
+		if p.Amount.Ccy != ccy || !p.Transaction.Date.Before(date) || !isSubAccount(p.Account.Name, account) {
+			continue
+		}
+		total = total.MustAdd(*p.Amount)
+	}
+	return total
+}
@@ -0,0 +1,70 @@
+package bean
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_padTransactions(t *testing.T) {
+	tx := Transaction{Date: time.Date(2023, time.January, 2, 0, 0, 0, 0, time.UTC)}
+	val := MustNewAmount("40", "GBP")
+	postings := []Posting{
+		{Account: Account{"Assets:Bank"}, Amount: &val, Transaction: &tx},
+	}
+	pads := []Pad{{
+		Date:    time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC),
+		PadTo:   Account{"Assets:Bank"},
+		PadFrom: Account{"Equity:Opening"},
+		Pos:     Pos{Line: 3},
+	}}
+	balances := []Balance{
+		{
+			Date:    time.Date(2023, time.January, 3, 0, 0, 0, 0, time.UTC),
+			Account: Account{"Assets:Bank"},
+			Amount:  MustNewAmount("100", "GBP"),
+		},
+		{
+			Date:    time.Date(2023, time.January, 3, 0, 0, 0, 0, time.UTC),
+			Account: Account{"Assets:Bank"},
+			Amount:  MustNewAmount("5", "USD"),
+		},
+		{
+			// only the first balance of each currency is padded
+			Date:    time.Date(2023, time.January, 4, 0, 0, 0, 0, time.UTC),
+			Account: Account{"Assets:Bank"},
+			Amount:  MustNewAmount("200", "GBP"),
+		},
+	}
+
+	// one padding transaction per currency
+	got, err := padTransactions(pads, balances, postings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("want 2 padding transactions, got %d", len(got))
+	}
+	want := []Amount{MustNewAmount("60", "GBP"), MustNewAmount("5", "USD")}
+	for i, tx := range got {
+		if tx.Type != padTxType || !tx.Date.Equal(pads[0].Date) {
+			t.Errorf("wrong padding transaction: %v", tx)
+		}
+		if !tx.Postings[0].Amount.Eq(want[i]) || !tx.Postings[1].Amount.Eq(want[i].Neg()) {
+			t.Errorf("wrong padding amount: want %v, got %v", want[i], tx.Postings[0].Amount)
+		}
+		if tx.Postings[1].Account.Name != "Equity:Opening" {
+			t.Errorf("wrong padding account: %v", tx.Postings[1].Account)
+		}
+	}
+
+	// pads with nothing to pad should error
+	balances = []Balance{{
+		Date:    time.Date(2023, time.January, 3, 0, 0, 0, 0, time.UTC),
+		Account: Account{"Assets:Bank"},
+		Amount:  MustNewAmount("40", "GBP"),
+	}}
+	_, err = padTransactions(pads, balances, postings)
+	if err == nil {
+		t.Error("unused pad should error")
+	}
+}
@@ -1,11 +1,10 @@
 package bean
 
 import (
-	"bufio"
+	"errors"
 	"fmt"
-	"io"
 	"log"
-	"unicode"
+	"strings"
 )
 
 const eol = "\n"
@@ -25,17 +24,24 @@ const (
 	dirCommodity dirType = "commodity"
 	dirQuery     dirType = "query"
 	dirCustom    dirType = "custom"
+	dirDocument  dirType = "document"
+	dirEvent     dirType = "event"
 	dirOption    dirType = "option"
+	dirInclude   dirType = "include"
+	dirPlugin    dirType = "plugin"
+	dirPushtag   dirType = "pushtag"
+	dirPoptag    dirType = "poptag"
+	dirPushmeta  dirType = "pushmeta"
+	dirPopmeta   dirType = "popmeta"
 )
 
-// Token is raw token from input file with a bunch of flags
-// quotes are removed, newlines inside quotes are maintained
+// Token is raw token from input file with its Kind.
+// Quotes are removed from strings, newlines inside quotes are maintained
 type Token struct {
-	LineNum int
+	LineNum int32
+	Column  int32 // of the first byte, starting at 1
+	Kind    TokenKind
 	Indent  bool
-	Quote   bool
-	Comment bool
-	EOL     bool
 	Text    string
 }
 
@@ -50,7 +56,7 @@ func (l Line) LineNum() int {
 	if l.Blank {
 		return -1
 	}
-	return l.Tokens[0].LineNum
+	return int(l.Tokens[0].LineNum)
 }
 
 func (l Line) String() string {
@@ -64,6 +70,7 @@ func (l Line) String() string {
 // Directive is one or more lines that go together
 type Directive struct {
 	Lines []Line
+	File  string // empty if not loaded from a file
 }
 
 // LineNum returns the soruce file number of the
@@ -72,128 +79,87 @@ func (d Directive) LineNum() int {
 	return d.Lines[0].LineNum()
 }
 
-func (d Directive) String() string {
-	str := ""
-	for _, l := range d.Lines {
-		str += fmt.Sprint(l, "\n")
-	}
-	str += "\n"
-	return str
+// Pos returns the source file position of this Directive
+func (d Directive) Pos() Pos {
+	return Pos{File: d.File, Line: d.LineNum()}
+}
+
+// Pos is the position of an entry in its source file
+type Pos struct {
+	File string
+	Line int
 }
 
-// getTokens loads all text from the file at `path`
-// loading a single rune at a time.
-// Tokens are manually split on newlines and spaces
-// and an EOL is added at the end
-// The result is a slice of tokens with EOLs that can be
-// used to separate lines
-func getTokens(rc io.ReadCloser) ([]Token, error) {
-	scanner := bufio.NewScanner(rc)
-	scanner.Split(bufio.ScanRunes)
-
-	tokens := make([]Token, 0, 1000) // TODO: estimate size needed?
-
-	type stateType struct {
-		current     string // current token accumulator
-		prev        string // used to check if prev rune was EOL
-		inQuotes    bool   // whether current runes are in quotes
-		inComment   bool   // are current runes inside a comment token
-		tokenQuoted bool   // whether last finished token was in quotes
-		indented    bool   // does the current token follow an indent
+func (p Pos) String() string {
+	if p.File == "" {
+		return fmt.Sprintf("line %d", p.Line)
 	}
-	lineNum := 1
-	s := stateType{}
-
-	for scanner.Scan() {
-		r := scanner.Text()
-		isEOL := r == eol
-		isSpace := unicode.IsSpace([]rune(r)[0])
-		onNewline := s.prev == "" || s.prev == eol
-		s.indented = s.indented || (onNewline && isSpace)
-		if isEOL || isSpace {
-			// EOL or space will close a token
-			// unless we are in a commend/quotation
-			if s.inQuotes || (s.inComment && !isEOL) {
-				s.current += r
-			} else {
-				// dont add empty tokens
-				if s.current != "" {
-					t := Token{
-						Indent:  s.indented,
-						Quote:   s.tokenQuoted,
-						Comment: s.inComment,
-						LineNum: lineNum,
-						Text:    s.current,
-					}
-					tokens = append(tokens, t)
-					s = stateType{} // reset state
-				}
-				// insert EOL so that subsequent funcs can split lines
-				if isEOL {
-					tokens = append(tokens, Token{
-						EOL:     true,
-						LineNum: lineNum,
-					})
-					s = stateType{} // reset state
-				}
-			}
-		} else if r == "\"" {
-			if s.inQuotes {
-				// we are closing quotes, and the accumulated token will be quoted
-				s.tokenQuoted = true
+	return fmt.Sprintf("%s:%d", p.File, p.Line)
+}
+
+// Text returns the source text of the Directive,
+// reconstructed from its Tokens (without comments)
+func (d Directive) Text() string {
+	var b strings.Builder
+	for i, line := range d.Lines {
+		if i > 0 {
+			b.WriteString("\n  ")
+		}
+		for j, t := range line.Tokens {
+			if j > 0 {
+				b.WriteString(" ")
 			}
-			s.inQuotes = !s.inQuotes
-		} else {
-			if r == ";" || (r == "*" && onNewline) {
-				// * only counts as a comment if it's the first rune on a line
-				s.inComment = true
+			if t.Kind == TokenString {
+				b.WriteString(Quote(t.Text))
+			} else {
+				b.WriteString(t.Text)
 			}
-			s.current += r
-		}
-		s.prev = r
-		if isEOL {
-			lineNum++
 		}
 	}
-	if err := scanner.Err(); err != nil {
-		// havent seen this yet, lets be loud about it!
-		panic(fmt.Errorf("in getTokens: %w", err))
+	return b.String()
+}
+
+func (d Directive) String() string {
+	str := ""
+	for _, l := range d.Lines {
+		str += fmt.Sprint(l, "\n")
 	}
-	// manually added to make subsequent funcs lives easier
-	tokens = append(tokens, Token{
-		LineNum: lineNum,
-		EOL:     true,
-	})
-	debugTokens(tokens)
-	rc.Close()
-	return tokens, nil
+	str += "\n"
+	return str
 }
 
 // makesLines simply splits the slice of Token
-// into a nested slice with Tokens groups into Lines
+// into a nested slice with Tokens groups into Lines.
+// The Tokens of each Line share the backing array of tokens.
 func makeLines(tokens []Token) ([]Line, error) {
-	var lines []Line
-	curLine := Line{}
-	prevToken := Token{}
-
-	for _, t := range tokens {
-		if t.Comment {
-			log.Printf("ignoring comment %s", t.Text)
-		} else if t.EOL {
-			// blank lines are semantically significant
-			// as they end directives
-			if prevToken.EOL {
-				lines = append(lines, Line{Blank: true})
-			}
-			// otherwise ignore lines with no tokens
-			if len(curLine.Tokens) > 0 {
-				lines = append(lines, curLine)
-				curLine = Line{}
+	lines := make([]Line, 0, len(tokens)/4)
+	start := 0 // index of the first Token on the current line
+	prevEOL := false
+
+	for i, t := range tokens {
+		if t.Kind != TokenEOL {
+			prevEOL = false
+			continue
+		}
+		// blank lines are semantically significant
+		// as they end directives
+		if prevEOL {
+			lines = append(lines, Line{Blank: true})
+		}
+		end := i
+		// comments run to the end of the line
+		if end > start && tokens[end-1].Kind == TokenComment {
+			if debug {
+				log.Printf("ignoring comment %s", tokens[end-1].Text)
 			}
-		} else {
-			curLine.Tokens = append(curLine.Tokens, t)
+			end--
+		}
+		// otherwise ignore lines with no tokens
+		if end > start {
+			lines = append(lines, Line{Tokens: tokens[start:end:end]})
 		}
-		prevToken = t
+		start = i + 1
+		prevEOL = true
 	}
 	return lines, nil
 }
@@ -201,44 +167,48 @@ func makeLines(tokens []Token) ([]Line, error) {
 // makeDirectives groups together Lines that are logically joined.
 // The 'root' line is always unindented, and subsequent lines
 // must be indented to form part of the directive.
-// Metadata of the form key:value (lower-case) can be added to any directive.
-// This is mostly used for adding Postings to Transactions
+// Metadata of the form key: value (lower-case) can be added to any directive.
+// Indented lines are mostly used for adding Postings to Transactions.
+// Indented lines outside a directive are skipped and returned as errors.
 func makeDirectives(lines []Line) ([]Directive, error) {
-	var directives []Directive
-	var curDirective Directive
-
-	appendAndBlank := func() {
-		if len(curDirective.Lines) > 0 {
-			directives = append(directives, curDirective)
+	directives := make([]Directive, 0, len(lines)/3)
+	start := -1 // index of the root line of the current directive
+	var errs []error
+
+	// the Lines of each Directive share the backing array of lines
+	appendAndBlank := func(end int) {
+		if start >= 0 {
+			directives = append(directives, Directive{Lines: lines[start:end:end]})
 		}
-		curDirective = Directive{}
+		start = -1
 	}
 
-	for _, line := range lines {
+	for i, line := range lines {
 		// a blank line always ends a directive
 		if line.Blank {
-			log.Println("blank")
-			appendAndBlank()
+			if debug {
+				log.Println("blank")
+			}
+			appendAndBlank(i)
 		} else if line.Tokens[0].Indent {
-			if len(curDirective.Lines) == 0 {
-				return nil, fmt.Errorf("indented expression outside directive: %s", line)
+			if start < 0 {
+				err := errorAt(Pos{Line: line.LineNum()}, "indented expression outside directive: %s", line)
+				err.Column = int(line.Tokens[0].Column)
+				errs = append(errs, err)
+				continue
 			}
-			log.Println("indent")
-			r := rune(line.Tokens[0].Text[0])
-			if unicode.IsLower(r) {
-				log.Println("ignore Tags:", line.Tokens[0].Text)
-			} else {
-				curDirective.Lines = append(curDirective.Lines, line)
+			if debug {
+				log.Println("indent")
 			}
-		} else if line.Tokens[0].Text == string(dirOption) {
-			// TODO do something with options
-			log.Println("ignore: option")
 		} else {
-			log.Println("normal", line.Tokens[0].Text)
-			appendAndBlank()
-			curDirective.Lines = append(curDirective.Lines, line)
+			if debug {
+				log.Println("normal", line.Tokens[0].Text)
+			}
+			appendAndBlank(i)
+			start = i
 		}
 	}
+	appendAndBlank(len(lines))
 	log.Println()
-	return directives, nil
+	return directives, errors.Join(errs...)
 }
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 )
 
 func TestGetTokens(t *testing.T) {
@@ -19,20 +20,20 @@ func TestGetTokens(t *testing.T) {
 	rc := io.NopCloser(strings.NewReader(text))
 	got, _ := getTokens(rc)
 	want := []Token{
-		{LineNum: 1, EOL: true},
-		{LineNum: 2, Comment: true, Text: "** Transactions"},
-		{LineNum: 2, EOL: true},
-		{LineNum: 3, Text: "2023-02-01"},
-		{LineNum: 3, Text: "*"},
-		{LineNum: 3, Quote: true, Text: "Salary"},
-		{LineNum: 3, EOL: true},
-		{LineNum: 4, Indent: true, Text: "Assets:Bank"},
-		{LineNum: 4, Text: "1000"},
-		{LineNum: 4, Text: "GBP"},
-		{LineNum: 4, EOL: true},
-		{LineNum: 5, Indent: true, Text: "Income:Job"},
-		{LineNum: 5, EOL: true},
-		{LineNum: 6, EOL: true},
+		{Kind: TokenEOL, LineNum: 1},
+		{Kind: TokenComment, LineNum: 2, Column: 1, Text: "** Transactions"},
+		{Kind: TokenEOL, LineNum: 2},
+		{Kind: TokenDate, LineNum: 3, Column: 1, Text: "2023-02-01"},
+		{Kind: TokenFlag, LineNum: 3, Column: 12, Text: "*"},
+		{Kind: TokenString, LineNum: 3, Column: 14, Text: "Salary"},
+		{Kind: TokenEOL, LineNum: 3},
+		{Kind: TokenAccount, LineNum: 4, Column: 3, Indent: true, Text: "Assets:Bank"},
+		{Kind: TokenNumber, LineNum: 4, Column: 40, Text: "1000"},
+		{Kind: TokenCurrency, LineNum: 4, Column: 45, Text: "GBP"},
+		{Kind: TokenEOL, LineNum: 4},
+		{Kind: TokenAccount, LineNum: 5, Column: 3, Indent: true, Text: "Income:Job"},
+		{Kind: TokenEOL, LineNum: 5},
+		{Kind: TokenEOL, LineNum: 6},
 	}
 	if diff := cmp.Diff(want, got); diff != "" {
 		t.Error(diff)
@@ -50,7 +51,7 @@ func Test_Line_LineNum(t *testing.T) {
 
 func Test_Directive_LineNum(t *testing.T) {
 	line := Line{false, []Token{{LineNum: 2}}}
-	directive := Directive{[]Line{line}}
+	directive := Directive{Lines: []Line{line}}
 	got := directive.LineNum()
 	want := 2
 	if got != want {
@@ -71,7 +72,7 @@ func TestMakeDirectives(t *testing.T) {
 		t.Error("parse should fail with indented expression outside directive")
 	}
 
-	// tags should be ignored
+	// metadata and options should be kept
 	text = `
 option "operating_currency" "GBP"
 2023-02-01 * "Salary"
@@ -83,11 +84,20 @@ option "operating_currency" "GBP"
 	tokens, _ = getTokens(rc)
 	lines, _ = makeLines(tokens)
 	got, _ := makeDirectives(lines)
-	want := []Directive{{[]Line{
+	want := []Directive{{Lines: []Line{
+		{Tokens: []Token{
+			{LineNum: 2, Text: "option"},
+			{LineNum: 2, Kind: TokenString, Text: "operating_currency"},
+			{LineNum: 2, Kind: TokenString, Text: "GBP"},
+		}},
+	}}, {Lines: []Line{
 		{Tokens: []Token{
 			{LineNum: 3, Text: "2023-02-01"},
 			{LineNum: 3, Text: "*"},
-			{LineNum: 3, Quote: true, Text: "Salary"},
+			{LineNum: 3, Kind: TokenString, Text: "Salary"},
+		}},
+		{Tokens: []Token{
+			{LineNum: 4, Indent: true, Text: "tag:value"},
 		}},
 		{Tokens: []Token{
 			{LineNum: 5, Indent: true, Text: "Assets:Bank"},
@@ -98,6 +108,23 @@ option "operating_currency" "GBP"
 			{LineNum: 6, Indent: true, Text: "Income:Job"},
 		}},
 	}}}
+	if diff := cmp.Diff(want, got, cmpopts.IgnoreFields(Token{}, "Kind", "Column")); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestGetTokens_punctuation(t *testing.T) {
+	// cost and price punctuation should be split into tokens
+	text := `  Assets:Invest 10 GOO {{150 USD, "lot1"}} @@ 1.2 EUR`
+	rc := io.NopCloser(strings.NewReader(text))
+	tokens, _ := getTokens(rc)
+	var got []string
+	for _, t := range tokens {
+		if t.Kind != TokenEOL {
+			got = append(got, t.Text)
+		}
+	}
+	want := []string{"Assets:Invest", "10", "GOO", "{{", "150", "USD", ",", "lot1", "}}", "@@", "1.2", "EUR"}
 	if diff := cmp.Diff(want, got); diff != "" {
 		t.Error(diff)
 	}
@@ -10,26 +10,58 @@ import (
 // Posting is an individual leg of a transaction
 type Posting struct {
 	Account     Account
-	Amount      *Amount      // to allow nil
+	Amount      *Amount // to allow nil
+	Cost        *Cost   // nil if no cost basis given
+	Price       *Amount // nil if no price annotation given
+	PriceTotal  bool    // true if the price was given with @@
+	Meta        Meta
 	Transaction *Transaction // nil until exctractPostings is run
 }
 
 // newPosting creates a Posting from a Line
 // NB: Not from a Directive, as Postings are not directives!
+// Postings are of the form:
+// Account [Number Ccy] [{Cost}] [@ Price]
 func newPosting(line Line) (Posting, error) {
-	log.Println("newPosting", line.Tokens[0].Text)
+	if debug {
+		log.Println("newPosting", line.Tokens[0].Text)
+	}
 	tokens := line.Tokens
 	accountStr := tokens[0].Text
-	var amount *Amount
-	if len(tokens) >= 3 {
-		numberStr := tokens[1].Text
-		ccy := tokens[2].Text
-		amountVal := MustNewAmount(numberStr, ccy)
-		amount = &amountVal
-	}
 	posting := Posting{
 		Account: Account{AccountName(accountStr)},
-		Amount:  amount,
+	}
+	rest := tokens[1:]
+	if len(rest) >= 2 && rest[0].Kind == TokenNumber {
+		amount, err := NewAmount(rest[0].Text, rest[1].Text)
+		if err != nil {
+			return Posting{}, fmt.Errorf("in newPosting: %w", err)
+		}
+		posting.Amount = &amount
+		rest = rest[2:]
+	}
+	if len(rest) > 0 && (rest[0].Kind == TokenLCurl || rest[0].Kind == TokenLCurlCurl) {
+		cost, n, err := newCost(rest)
+		if err != nil {
+			return Posting{}, fmt.Errorf("in newPosting: %w", err)
+		}
+		posting.Cost = &cost
+		rest = rest[n:]
+	}
+	if len(rest) > 0 && (rest[0].Kind == TokenAt || rest[0].Kind == TokenAtAt) {
+		if len(rest) < 3 {
+			return Posting{}, fmt.Errorf("price annotation must have number and currency: %s", line)
+		}
+		price, err := NewAmount(rest[1].Text, rest[2].Text)
+		if err != nil {
+			return Posting{}, fmt.Errorf("in newPosting: %w", err)
+		}
+		posting.Price = &price
+		posting.PriceTotal = rest[0].Kind == TokenAtAt
+		rest = rest[3:]
+	}
+	if len(rest) > 0 {
+		return Posting{}, fmt.Errorf("unexpected tokens in posting: %s", line)
 	}
 	return posting, nil
 }
@@ -39,9 +71,45 @@ func (p Posting) String() string {
 	if p.Amount != nil {
 		amountStr = fmt.Sprintf("%v", p.Amount)
 	}
+	if p.Cost != nil {
+		amountStr += fmt.Sprintf(" %v", p.Cost)
+	}
+	if p.Price != nil {
+		at := "@"
+		if p.PriceTotal {
+			at = "@@"
+		}
+		amountStr += fmt.Sprintf(" %s %v", at, p.Price)
+	}
 	return fmt.Sprintf("%v: %v", p.Account.Name, amountStr)
 }
 
+// Weight is the Amount used to balance the Posting's Transaction.
+// It is the cost if there is one, otherwise the price,
+// otherwise simply the Amount.
+func (p Posting) Weight() Amount {
+	units := *p.Amount
+	switch {
+	case p.Cost != nil && p.Cost.Amount != nil:
+		return weightOf(units, *p.Cost.Amount, p.Cost.Total)
+	case p.Price != nil:
+		return weightOf(units, *p.Price, p.PriceTotal)
+	default:
+		return units
+	}
+}
+
+// weightOf converts units with a per-unit (or total) cost or price
+func weightOf(units Amount, per Amount, total bool) Amount {
+	if !total {
+		return per.Mul(units.Number)
+	}
+	if units.Number.Negative {
+		return per.Neg()
+	}
+	return per
+}
+
 // extractPostings flattens the Postings inside the slice of Transactions
 // into a single slice of Postings
 func extractPostings(transactions []Transaction) ([]Posting, error) {
@@ -57,36 +125,35 @@ func extractPostings(transactions []Transaction) ([]Posting, error) {
 
 // sortPostings must be applied before doing any calculations with the postings
 func sortPostings(postings []Posting) ([]Posting, error) {
-	sort.Slice(postings, func(i, j int) bool {
+	sort.SliceStable(postings, func(i, j int) bool {
 		return postings[i].Transaction.Date.Before(postings[j].Transaction.Date)
 	})
 	return postings, nil
 }
 
 // getBalances returns a map containing the balance for each account-ccy pair
+// at the start of date (so postings on date are not included)
 func getBalances(postings []Posting, atl AccountTimeLine, date time.Time) (AccBal, error) {
+	return getBalanceChanges(postings, atl, time.Time{}, date)
+}
+
+// getBalanceChanges returns a map containing the change for each account-ccy pair
+// between start (inclusive) and end (exclusive).
+// Postings must already be sorted.
+func getBalanceChanges(postings []Posting, atl AccountTimeLine, start time.Time, end time.Time) (AccBal, error) {
 	bals := make(AccBal, 20)
 	for _, p := range postings {
-		acc := p.Account.Name
-		num := p.Amount.Number
-		ccy := p.Amount.Ccy
-
+		if p.Transaction.Date.Before(start) {
+			continue
+		}
+		if !p.Transaction.Date.Before(end) {
+			break
+		}
 		open := openAtDate(atl, p)
 		if !open {
 			return nil, fmt.Errorf("account %s not open at date %s", p.Account, p.Transaction.Date.Format(time.DateOnly))
 		}
-
-		if bals[acc] == nil {
-			bals[acc] = make(CcyAmount, 3)
-		}
-
-		other := Amount{num, ccy}
-		cur, ok := bals[acc][ccy]
-		if ok {
-			bals[acc][ccy] = cur.MustAdd(other)
-		} else {
-			bals[acc][ccy] = other
-		}
+		addPosting(bals, p)
 	}
 	return bals, nil
 }
@@ -45,3 +45,92 @@ func Test_getBalances(t *testing.T) {
 		t.Errorf("getBalances should fail with non-open account")
 	}
 }
+
+func Test_getBalanceChanges(t *testing.T) {
+	val1 := MustNewAmount("100", "GBP")
+	val2 := MustNewAmount("-100", "GBP")
+	tx1 := Transaction{Date: time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC)}
+	tx2 := Transaction{Date: time.Date(2023, time.February, 1, 0, 0, 0, 0, time.UTC)}
+	postings := []Posting{
+		{Account: Account{"Assets:Bank"}, Amount: &val1, Transaction: &tx1},
+		{Account: Account{"Income:Job"}, Amount: &val2, Transaction: &tx1},
+		{Account: Account{"Assets:Bank"}, Amount: &val1, Transaction: &tx2},
+		{Account: Account{"Income:Job"}, Amount: &val2, Transaction: &tx2},
+	}
+	atl := AccountTimeLine{
+		"Assets:Bank": {{Date: tx1.Date, Open: true, Account: Account{"Assets:Bank"}}},
+		"Income:Job":  {{Date: tx1.Date, Open: true, Account: Account{"Income:Job"}}},
+	}
+	comparer := cmp.Comparer(func(x, y Amount) bool {
+		return x.Eq(y)
+	})
+
+	// balances should stop at the start of date
+	got, _ := getBalances(postings, atl, tx2.Date)
+	want := AccBal{
+		"Assets:Bank": MustNewCcyAmount(map[string]string{"GBP": "100"}),
+		"Income:Job":  MustNewCcyAmount(map[string]string{"GBP": "-100"}),
+	}
+	if diff := cmp.Diff(want, got, comparer); diff != "" {
+		t.Error(diff)
+	}
+
+	// changes should include start and exclude end
+	got, _ = getBalanceChanges(postings, atl, tx2.Date, tx2.Date.AddDate(0, 1, 0))
+	if diff := cmp.Diff(want, got, comparer); diff != "" {
+		t.Error(diff)
+	}
+	got, _ = getBalanceChanges(postings, atl, tx1.Date.AddDate(0, 0, 1), tx2.Date)
+	if diff := cmp.Diff(AccBal{}, got, comparer); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func Test_newPosting(t *testing.T) {
+	// cost and price should be parsed
+	line := Line{Tokens: tokensOf("Assets:Invest", "10", "GOO", "{", "150", "USD", "}", "@@", "1600", "USD")}
+	got, err := newPosting(line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Cost == nil || got.Price == nil || !got.PriceTotal {
+		t.Fatalf("cost and price should be set: %v", got)
+	}
+	if want := "Assets:Invest: 10 GOO {150 USD} @@ 1600 USD"; got.String() != want {
+		t.Errorf("want %s, got %s", want, got)
+	}
+
+	// extra tokens should error
+	line = Line{Tokens: tokensOf("Assets:Invest", "10", "GOO", "GOO")}
+	if _, err := newPosting(line); err == nil {
+		t.Error("extra posting tokens should error")
+	}
+
+	// price without amount should error
+	line = Line{Tokens: tokensOf("Assets:Invest", "10", "GOO", "@", "1")}
+	if _, err := newPosting(line); err == nil {
+		t.Error("incomplete price should error")
+	}
+}
+
+func Test_Posting_Weight(t *testing.T) {
+	units := MustNewAmount("-10", "GOO")
+	perCost := MustNewAmount("150", "USD")
+	totalCost := MustNewAmount("1500", "USD")
+	price := MustNewAmount("1.2", "EUR")
+	cases := []struct {
+		posting Posting
+		want    Amount
+	}{
+		{Posting{Amount: &units}, units},
+		{Posting{Amount: &units, Cost: &Cost{Amount: &perCost}}, MustNewAmount("-1500", "USD")},
+		{Posting{Amount: &units, Cost: &Cost{Amount: &totalCost, Total: true}}, MustNewAmount("-1500", "USD")},
+		{Posting{Amount: &units, Cost: &Cost{}, Price: &price}, MustNewAmount("-12", "EUR")},
+		{Posting{Amount: &units, Price: &price, PriceTotal: true}, MustNewAmount("-1.2", "EUR")},
+	}
+	for _, c := range cases {
+		if got := c.posting.Weight(); !got.Eq(c.want) {
+			t.Errorf("%v: want %v, got %v", c.posting, c.want, got)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package bean
+
+import (
+	"log"
+	"sort"
+	"time"
+
+	"github.com/cockroachdb/apd/v3"
+)
+
+// priceSource ranks rates on the same date, highest wins
+type priceSource int
+
+const (
+	sourceImplicitInverse priceSource = iota // inverted from a posting
+	sourceImplicit                           // from a posting @ price or {cost}
+	sourceInverse                            // inverted from a price directive
+	sourceDirective                          // from a price directive
+)
+
+// ccyPair is the price of Base in Quote
+type ccyPair struct {
+	Base  Ccy
+	Quote Ccy
+}
+
+// rate is one dated price of a ccyPair
+type rate struct {
+	Date   time.Time
+	Rate   apd.Decimal
+	Source priceSource
+}
+
+// PriceMap holds the price of every currency pair over time,
+// from price directives and implied by postings
+type PriceMap struct {
+	rates  map[ccyPair][]rate // sorted by date, then source
+	quotes map[Ccy][]Ccy      // currencies that each currency is priced in, sorted
+}
+
+// NewPriceMap creates a PriceMap from Price directives and the
+// prices implied by the @ prices and {costs} of postings
+func NewPriceMap(prices []Price, transactions []Transaction) *PriceMap {
+	pm := &PriceMap{rates: make(map[ccyPair][]rate), quotes: make(map[Ccy][]Ccy)}
+	for _, p := range prices {
+		pm.add(p.Date, p.Ccy, p.Amount, sourceDirective)
+	}
+	for _, tx := range transactions {
+		if tx.Type == padTxType {
+			continue
+		}
+		for _, p := range tx.Postings {
+			if price, ok := impliedPrice(p); ok {
+				pm.add(tx.Date, p.Amount.Ccy, price, sourceImplicit)
+			}
+		}
+	}
+	for pair, rates := range pm.rates {
+		sort.SliceStable(rates, func(i, j int) bool {
+			if !rates[i].Date.Equal(rates[j].Date) {
+				return rates[i].Date.Before(rates[j].Date)
+			}
+			return rates[i].Source < rates[j].Source
+		})
+		pm.quotes[pair.Base] = append(pm.quotes[pair.Base], pair.Quote)
+	}
+	for _, quotes := range pm.quotes {
+		sort.Slice(quotes, func(i, j int) bool { return quotes[i] < quotes[j] })
+	}
+	return pm
+}
+
+// impliedPrice returns the per-unit price of a Posting from its
+// @ price, or else its {cost}
+func impliedPrice(p Posting) (Amount, bool) {
+	if p.Amount == nil || p.Amount.Number.IsZero() {
+		return Amount{}, false
+	}
+	var per *Amount
+	total := false
+	switch {
+	case p.Price != nil:
+		per, total = p.Price, p.PriceTotal
+	case p.Cost != nil && p.Cost.Amount != nil:
+		per, total = p.Cost.Amount, p.Cost.Total
+	default:
+		return Amount{}, false
+	}
+	if !total {
+		return *per, true
+	}
+	price, err := per.Quo(p.Amount.Abs().Number)
+	if err != nil {
+		return Amount{}, false
+	}
+	return price, true
+}
+
+// add adds the price of base, and the inverse price of the quote currency
+func (pm *PriceMap) add(date time.Time, base Ccy, price Amount, source priceSource) {
+	if base == price.Ccy || price.Number.IsZero() {
+		return
+	}
+	if debug {
+		log.Println("addPrice", date.Format(time.DateOnly), base, price)
+	}
+	pair := ccyPair{base, price.Ccy}
+	pm.rates[pair] = append(pm.rates[pair], rate{date, price.Number, source})
+	inverse := apd.Decimal{}
+	if _, err := apdQuoCtx.Quo(&inverse, apd.New(1, 0), &price.Number); err != nil {
+		return
+	}
+	inverse.Reduce(&inverse)
+	pair = ccyPair{price.Ccy, base}
+	pm.rates[pair] = append(pm.rates[pair], rate{date, inverse, source - 1})
+}
+
+// direct returns the latest rate of base in quote on or before date
+func (pm *PriceMap) direct(base Ccy, quote Ccy, date time.Time) (rate, bool) {
+	rates := pm.rates[ccyPair{base, quote}]
+	i := sort.Search(len(rates), func(i int) bool { return rates[i].Date.After(date) })
+	if i == 0 {
+		return rate{}, false
+	}
+	return rates[i-1], true
+}
+
+// Price returns the price of one unit of base in quote, using the latest
+// price on or before date. If there is no price for the pair (or its inverse)
+// it triangulates through another currency, preferring the most recent prices.
+// It returns false if no price is known.
+func (pm *PriceMap) Price(base Ccy, quote Ccy, date time.Time) (Amount, bool) {
+	if base == quote {
+		return Amount{*apd.New(1, 0), quote}, true
+	}
+	if r, ok := pm.direct(base, quote, date); ok {
+		return Amount{r.Rate, quote}, true
+	}
+	var best Amount
+	var bestDate time.Time
+	found := false
+	for _, via := range pm.quotes[base] {
+		if via == quote {
+			continue
+		}
+		first, ok := pm.direct(base, via, date)
+		if !ok {
+			continue
+		}
+		second, ok := pm.direct(via, quote, date)
+		if !ok {
+			continue
+		}
+		// the age of a triangulated price is that of its older leg
+		older := first.Date
+		if second.Date.Before(older) {
+			older = second.Date
+		}
+		if found && !older.After(bestDate) {
+			continue
+		}
+		best = Amount{first.Rate, via}.Mul(second.Rate)
+		best.Ccy = quote
+		bestDate, found = older, true
+	}
+	return best, found
+}
+
+// Convert returns amt in quote at the price on date,
+// or false if no price is known
+func (pm *PriceMap) Convert(amt Amount, quote Ccy, date time.Time) (Amount, bool) {
+	price, ok := pm.Price(amt.Ccy, quote, date)
+	if !ok {
+		return Amount{}, false
+	}
+	return price.Mul(amt.Number), true
+}
@@ -0,0 +1,68 @@
+package bean
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPriceMap(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2023, 1, d, 0, 0, 0, 0, time.UTC) }
+	prices := []Price{
+		{Date: day(1), Ccy: "GOO", Amount: MustNewAmount("50", "GBP")},
+		{Date: day(5), Ccy: "GOO", Amount: MustNewAmount("60", "GBP")},
+		{Date: day(1), Ccy: "GBP", Amount: MustNewAmount("1.25", "USD")},
+	}
+	cost := MustNewAmount("200", "EUR")
+	price := MustNewAmount("40", "GBP")
+	transactions := []Transaction{
+		{Date: day(3), Postings: []Posting{
+			{Account: Account{"Assets:Invest"}, Amount: amtPtr("2", "AAA"), Cost: &Cost{Amount: &cost, Total: true}},
+		}},
+		{Date: day(4), Postings: []Posting{
+			{Account: Account{"Assets:Invest"}, Amount: amtPtr("-1", "GOO"), Cost: &Cost{Amount: &cost}, Price: &price},
+		}},
+	}
+	pm := NewPriceMap(prices, transactions)
+
+	tests := []struct {
+		name  string
+		base  Ccy
+		quote Ccy
+		date  time.Time
+		want  string
+	}{
+		{"same", "GBP", "GBP", day(1), "1 GBP"},
+		{"direct", "GOO", "GBP", day(2), "50 GBP"},
+		{"latest before", "GOO", "GBP", day(9), "60 GBP"},
+		{"implicit from price", "GOO", "GBP", day(4), "40 GBP"},
+		{"implicit from total cost", "AAA", "EUR", day(3), "100 EUR"},
+		{"inverse", "GBP", "GOO", day(2), "0.02 GOO"},
+		{"triangulated", "GOO", "USD", day(2), "62.50 USD"},
+		{"triangulated inverse", "USD", "GOO", day(2), "0.016 GOO"},
+	}
+	for _, tt := range tests {
+		got, ok := pm.Price(tt.base, tt.quote, tt.date)
+		if !ok {
+			t.Errorf("%s: no price", tt.name)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("%s: want %s, got %v", tt.name, tt.want, got)
+		}
+	}
+
+	if _, ok := pm.Price("GOO", "GBP", time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)); ok {
+		t.Error("should have no price before the first")
+	}
+	if _, ok := pm.Price("GOO", "JPY", day(9)); ok {
+		t.Error("should have no price for unknown currency")
+	}
+	if got, ok := pm.Convert(MustNewAmount("3", "GOO"), "GBP", day(9)); !ok || got.String() != "180 GBP" {
+		t.Errorf("wrong conversion: %v", got)
+	}
+}
+
+func amtPtr(num string, ccy string) *Amount {
+	amt := MustNewAmount(num, ccy)
+	return &amt
+}
@@ -0,0 +1,100 @@
+package bean
+
+import (
+	"errors"
+	"io"
+	"strings"
+)
+
+// SourceEntry is an Entry as written in a file, with the lines it spans.
+// Pushed tags and metadata are not applied, and Transactions are
+// not booked or interpolated.
+type SourceEntry struct {
+	Entry    Entry
+	LastLine int       // the first line is in the Pos of the Entry
+	Comments []Comment // the comments within the lines, in order
+}
+
+// Comment is a comment within a SourceEntry, attached to a line of the Entry.
+// Lines are counted from 0 for the first line of the Entry, and include
+// metadata and Postings but not lines of only tags and links, which are
+// part of the first line of a Transaction.
+type Comment struct {
+	Text   string // including the leading ; or *
+	Line   int    // the line the comment is on, or the line it follows
+	Inline bool   // true if the comment ends the line, false if it is on its own line
+}
+
+// ParseEntries reads the Entries from rc as they are written,
+// for tools that rewrite files. Includes are not followed, and
+// other directives (option, include, pushtag etc) are skipped.
+func ParseEntries(rc io.ReadCloser) ([]SourceEntry, error) {
+	tokens, lexErr := getTokens(rc)
+	comments := make(map[int32]Token)
+	for _, t := range tokens {
+		if t.Kind == TokenComment {
+			comments[t.LineNum] = t
+		}
+	}
+	// makeLines never errors currently
+	lines, _ := makeLines(tokens)
+	directives, err := makeDirectives(lines)
+	errs := []error{lexErr, err}
+
+	entries := make([]SourceEntry, 0, len(directives))
+	for _, directive := range directives {
+		switch dirType(directive.Lines[0].Tokens[0].Text) {
+		case dirOption, dirPlugin, dirInclude, dirPushtag, dirPoptag, dirPushmeta, dirPopmeta:
+			continue
+		}
+		e, err := newEntry(directive)
+		if err != nil {
+			errs = append(errs, directiveError(directive, err))
+			continue
+		}
+		last := lastLine(directive)
+		entries = append(entries, SourceEntry{Entry: e, LastLine: last, Comments: entryComments(directive, last, comments)})
+	}
+	return entries, errors.Join(errs...)
+}
+
+// entryComments returns the comments from the first line
+// of the Directive to last, attached to the lines of the Entry
+func entryComments(directive Directive, last int, comments map[int32]Token) []Comment {
+	if len(comments) == 0 {
+		return nil
+	}
+	var res []Comment
+	index := -1 // the line of the Entry that the current source line is part of
+	next := 0   // the next Line of the Directive
+	for num := int32(directive.Pos().Line); num <= int32(last); num++ {
+		inline := false
+		if next < len(directive.Lines) && directive.Lines[next].Tokens[0].LineNum == num {
+			if next == 0 || !isTagLine(directive.Lines[next]) {
+				index++
+				inline = true
+			}
+			next++
+		}
+		if t, ok := comments[num]; ok {
+			res = append(res, Comment{Text: t.Text, Line: index, Inline: inline})
+		}
+	}
+	return res
+}
+
+// lastLine returns the last line number of the Directive,
+// including strings that run over several lines
+func lastLine(directive Directive) int {
+	last := 0
+	for _, line := range directive.Lines {
+		for _, t := range line.Tokens {
+			end := int(t.LineNum)
+			if t.Kind == TokenString {
+				end += strings.Count(t.Text, "\n")
+			}
+			last = max(last, end)
+		}
+	}
+	return last
+}
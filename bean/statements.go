@@ -0,0 +1,297 @@
+package bean
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ReportOptions are the period and currency of a financial statement
+type ReportOptions struct {
+	From      time.Time // start of the period (inclusive), zero for all time
+	To        time.Time // end of the period (exclusive), and the date of a BalanceSheet
+	Ccy       Ccy       // convert to this currency, empty for no conversion
+	Valuation Valuation // how to convert, market if empty
+}
+
+// valuation returns the Valuation to use, which is units if there is no Ccy
+func (o ReportOptions) valuation() Valuation {
+	switch {
+	case o.Ccy == "":
+		return ValueUnits
+	case o.Valuation == "":
+		return ValueMarket
+	}
+	return o.Valuation
+}
+
+// BalanceSheet is the Assets, Liabilities and Equity at the start of a date.
+// The Income and Expenses of the period are folded into Equity as
+// Earnings:Current, and those from before the period as Earnings:Previous.
+// Whatever else is left, such as unrealized gains when converted at market
+// value, is put in Equity:Conversions so that the three sections sum to zero.
+type BalanceSheet struct {
+	Date        time.Time    `json:"date"`
+	Ccy         Ccy          `json:"currency,omitempty"`
+	Valuation   Valuation    `json:"valuation"`
+	Assets      *AccountTree `json:"assets"`
+	Liabilities *AccountTree `json:"liabilities"`
+	Equity      *AccountTree `json:"equity"`
+	Unpriced    AccBal       `json:"unpriced,omitempty"` // amounts that could not be converted
+}
+
+// IncomeStatement is the Income and Expenses over a period
+type IncomeStatement struct {
+	From      time.Time    `json:"from"`
+	To        time.Time    `json:"to"`
+	Ccy       Ccy          `json:"currency,omitempty"`
+	Valuation Valuation    `json:"valuation"`
+	Income    *AccountTree `json:"income"`
+	Expenses  *AccountTree `json:"expenses"`
+	NetIncome CcyAmount    `json:"net_income"`         // Income less Expenses, positive for a profit
+	Unpriced  AccBal       `json:"unpriced,omitempty"` // amounts that could not be converted
+}
+
+// isIncomeStatement returns true for Income and Expenses accounts
+func (o Options) isIncomeStatement(acc AccountName) bool {
+	return isSubAccount(acc, AccountName(o.NameIncome)) || isSubAccount(acc, AccountName(o.NameExpenses))
+}
+
+// earningsAccount returns Equity:Earnings:<period>
+func (o Options) earningsAccount(period string) AccountName {
+	return AccountName(o.NameEquity + ":Earnings:" + period)
+}
+
+// conversionsAccount returns Equity:Conversions
+func (o Options) conversionsAccount() AccountName {
+	return AccountName(o.NameEquity + ":Conversions")
+}
+
+// BalanceSheet returns the BalanceSheet at the start of opts.To,
+// converted to opts.Ccy at the prices on that date
+func (l *Ledger) BalanceSheet(opts ReportOptions) (*BalanceSheet, error) {
+	valuation := opts.valuation()
+	bals, err := l.GetBalancesIn(opts.To, opts.Ccy, valuation)
+	if err != nil {
+		return nil, fmt.Errorf("in BalanceSheet: %w", err)
+	}
+	var previous, previousUnpriced AccBal
+	if !opts.From.IsZero() {
+		changes, err := l.GetBalanceChanges(time.Time{}, opts.From)
+		if err != nil {
+			return nil, fmt.Errorf("in BalanceSheet: %w", err)
+		}
+		previous, previousUnpriced, err = l.convertChanges(changes, opts.Ccy, valuation, time.Time{}, opts.From, opts.To)
+		if err != nil {
+			return nil, fmt.Errorf("in BalanceSheet: %w", err)
+		}
+	}
+
+	bs := &BalanceSheet{Date: opts.To, Ccy: opts.Ccy, Valuation: valuation}
+	fold := func(bals AccBal, previous AccBal) AccBal {
+		res := AccBal{}
+		for acc, ca := range bals {
+			target := acc
+			if l.Options.isIncomeStatement(acc) {
+				target = l.Options.earningsAccount("Current")
+			}
+			for _, amt := range ca {
+				addAccBal(res, target, amt)
+			}
+		}
+		for acc, ca := range previous {
+			if !l.Options.isIncomeStatement(acc) {
+				continue
+			}
+			for _, amt := range ca {
+				addAccBal(res, l.Options.earningsAccount("Previous"), amt)
+				addAccBal(res, l.Options.earningsAccount("Current"), amt.Neg())
+			}
+		}
+		return res
+	}
+	folded := fold(bals.Balances, previous)
+	for _, amt := range sumAccBal(folded) {
+		if !amt.Number.IsZero() {
+			addAccBal(folded, l.Options.conversionsAccount(), amt.Neg())
+		}
+	}
+	tree := l.Tree(folded)
+	bs.Assets = section(tree, l.Options.NameAssets)
+	bs.Liabilities = section(tree, l.Options.NameLiabilities)
+	bs.Equity = section(tree, l.Options.NameEquity)
+	if unpriced := fold(bals.Unpriced, previousUnpriced); len(unpriced) > 0 {
+		bs.Unpriced = unpriced
+	}
+	return bs, nil
+}
+
+// IncomeStatement returns the IncomeStatement from opts.From to opts.To,
+// converted to opts.Ccy at the prices on opts.To
+func (l *Ledger) IncomeStatement(opts ReportOptions) (*IncomeStatement, error) {
+	valuation := opts.valuation()
+	changes, err := l.GetBalanceChanges(opts.From, opts.To)
+	if err != nil {
+		return nil, fmt.Errorf("in IncomeStatement: %w", err)
+	}
+	for acc := range changes {
+		if !l.Options.isIncomeStatement(acc) {
+			delete(changes, acc)
+		}
+	}
+	bals, unpriced, err := l.convertChanges(changes, opts.Ccy, valuation, opts.From, opts.To, opts.To)
+	if err != nil {
+		return nil, fmt.Errorf("in IncomeStatement: %w", err)
+	}
+	tree := l.Tree(bals)
+	is := &IncomeStatement{
+		From:      opts.From,
+		To:        opts.To,
+		Ccy:       opts.Ccy,
+		Valuation: valuation,
+		Income:    section(tree, l.Options.NameIncome),
+		Expenses:  section(tree, l.Options.NameExpenses),
+		NetIncome: CcyAmount{},
+	}
+	for _, amt := range tree.Total {
+		addToCcyAmount(is.NetIncome, amt.Neg())
+	}
+	if len(unpriced) > 0 {
+		is.Unpriced = unpriced
+	}
+	return is, nil
+}
+
+// convertChanges converts changes, the change in each account from start
+// to end, to ccy using valuation with the prices on date. The change is
+// taken between the converted balances at start and end, so that lots
+// are valued the same as in GetBalancesIn. Amounts with no price
+// are returned in unpriced.
+func (l *Ledger) convertChanges(changes AccBal, ccy Ccy, valuation Valuation, start, end, date time.Time) (converted AccBal, unpriced AccBal, err error) {
+	if valuation == ValueUnits {
+		return changes, AccBal{}, nil
+	}
+	before, err := l.GetInventories(start)
+	if err != nil {
+		return nil, nil, fmt.Errorf("in convertChanges: %w", err)
+	}
+	after, err := l.GetInventories(end)
+	if err != nil {
+		return nil, nil, fmt.Errorf("in convertChanges: %w", err)
+	}
+	from := l.convertInventories(before, ccy, valuation, date)
+	to := l.convertInventories(after, ccy, valuation, date)
+	converted, unpriced = AccBal{}, AccBal{}
+	for acc := range changes {
+		for _, amt := range to.Balances[acc] {
+			addAccBal(converted, acc, amt)
+		}
+		for _, amt := range from.Balances[acc] {
+			addAccBal(converted, acc, amt.Neg())
+		}
+		diff := CcyAmount{}
+		for _, amt := range to.Unpriced[acc] {
+			addToCcyAmount(diff, amt)
+		}
+		for _, amt := range from.Unpriced[acc] {
+			addToCcyAmount(diff, amt.Neg())
+		}
+		for _, amt := range diff {
+			if !amt.Number.IsZero() {
+				addAccBal(unpriced, acc, amt)
+			}
+		}
+	}
+	return converted, unpriced, nil
+}
+
+// sumAccBal returns the sum of all the balances in ab
+func sumAccBal(ab AccBal) CcyAmount {
+	res := CcyAmount{}
+	for _, ca := range ab {
+		for _, amt := range ca {
+			addToCcyAmount(res, amt)
+		}
+	}
+	return res
+}
+
+// section returns the root account called name from tree,
+// or an empty one if there are no balances under it
+func section(tree *AccountTree, name string) *AccountTree {
+	if node := tree.Find(AccountName(name)); node != nil {
+		return node
+	}
+	return &AccountTree{Name: AccountName(name), Balance: CcyAmount{}, Total: CcyAmount{}}
+}
+
+// sections joins root accounts under a nameless root, for printing
+func sections(nodes ...*AccountTree) *AccountTree {
+	return &AccountTree{Balance: CcyAmount{}, Total: CcyAmount{}, Children: nodes}
+}
+
+// total returns a node called name with the sum of the Totals of nodes
+func total(name string, nodes ...*AccountTree) *AccountTree {
+	res := &AccountTree{Name: AccountName(name), Balance: CcyAmount{}, Total: CcyAmount{}}
+	for _, node := range nodes {
+		for _, amt := range node.Total {
+			addToCcyAmount(res.Total, amt)
+		}
+	}
+	return res
+}
+
+// printTitle writes the underlined title of a statement,
+// with its currency if converted
+func printTitle(w io.Writer, title string, ccy Ccy, valuation Valuation) error {
+	if ccy != "" && valuation != ValueUnits {
+		title = fmt.Sprintf("%s (%s at %s value)", title, ccy, valuation)
+	}
+	_, err := fmt.Fprintf(w, "%s\n%s\n", title, strings.Repeat("=", len(title)))
+	return err
+}
+
+// printUnpriced writes the amounts that could not be converted, if any
+func printUnpriced(w io.Writer, unpriced AccBal, ccy Ccy, depth int) error {
+	if len(unpriced) == 0 {
+		return nil
+	}
+	if _, err := fmt.Fprintf(w, "\nUnpriced (no %s price):\n", ccy); err != nil {
+		return err
+	}
+	return PrintAccountTree(w, NewAccountTree(unpriced).Truncate(depth))
+}
+
+// PrintBalanceSheet writes the BalanceSheet with accounts to depth levels
+// (zero for all), followed by the total of Liabilities and Equity
+func PrintBalanceSheet(w io.Writer, bs *BalanceSheet, depth int) error {
+	title := "Balance sheet at " + bs.Date.Format(time.DateOnly)
+	if err := printTitle(w, title, bs.Ccy, bs.Valuation); err != nil {
+		return err
+	}
+	tree := sections(bs.Assets, bs.Liabilities, bs.Equity).Truncate(depth)
+	label := fmt.Sprintf("%s + %s", bs.Liabilities.Name, bs.Equity.Name)
+	if err := printAccountTree(w, tree, total(label, bs.Liabilities, bs.Equity)); err != nil {
+		return err
+	}
+	return printUnpriced(w, bs.Unpriced, bs.Ccy, depth)
+}
+
+// PrintIncomeStatement writes the IncomeStatement with accounts to depth levels
+// (zero for all), followed by the net income
+func PrintIncomeStatement(w io.Writer, is *IncomeStatement, depth int) error {
+	title := "Income statement to " + is.To.Format(time.DateOnly)
+	if !is.From.IsZero() {
+		title = fmt.Sprintf("Income statement from %s to %s", is.From.Format(time.DateOnly), is.To.Format(time.DateOnly))
+	}
+	if err := printTitle(w, title, is.Ccy, is.Valuation); err != nil {
+		return err
+	}
+	tree := sections(is.Income, is.Expenses).Truncate(depth)
+	net := &AccountTree{Name: "Net income", Total: is.NetIncome}
+	if err := printAccountTree(w, tree, net); err != nil {
+		return err
+	}
+	return printUnpriced(w, is.Unpriced, is.Ccy, depth)
+}
@@ -0,0 +1,170 @@
+package bean
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestStatements(t *testing.T) {
+	l := loadText(t, `
+option "operating_currency" "GBP"
+2023-01-01 open Assets:Bank
+2023-01-01 open Liabilities:Card
+2023-01-01 open Equity:Opening
+2023-01-01 open Income:Job
+2023-01-01 open Expenses:Food
+2023-01-01 open Expenses:Travel
+
+2023-01-01 * "Opening"
+  Assets:Bank  100 GBP
+  Equity:Opening
+
+2023-01-02 * "Salary"
+  Assets:Bank  1000 GBP
+  Income:Job
+
+2023-01-03 * "Lunch"
+  Expenses:Food  10 GBP
+  Liabilities:Card
+
+2023-02-01 * "Salary"
+  Assets:Bank  1000 GBP
+  Income:Job
+
+2023-02-02 * "Trip"
+  Expenses:Travel  100 USD
+  Liabilities:Card
+
+2023-02-02 price USD 0.8 GBP
+`)
+	feb := time.Date(2023, time.February, 1, 0, 0, 0, 0, time.UTC)
+	mar := time.Date(2023, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+	bs, err := l.BalanceSheet(ReportOptions{From: feb, To: mar})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	if err := PrintBalanceSheet(&b, bs, 0); err != nil {
+		t.Fatal(err)
+	}
+	want := `Balance sheet at 2023-03-01
+===========================
+Assets                 2100 GBP
+  Bank                 2100 GBP
+Liabilities             -10 GBP
+                       -100 USD
+  Card                  -10 GBP
+                       -100 USD
+Equity                -2090 GBP
+                        100 USD
+  Earnings            -1990 GBP
+                        100 USD
+    Current           -1000 GBP
+                        100 USD
+    Previous           -990 GBP
+  Opening              -100 GBP
+
+Liabilities + Equity  -2100 GBP
+                          0 USD
+`
+	if diff := cmp.Diff(want, b.String()); diff != "" {
+		t.Error(diff)
+	}
+
+	is, err := l.IncomeStatement(ReportOptions{From: feb, To: mar, Ccy: "GBP"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Reset()
+	if err := PrintIncomeStatement(&b, is, 1); err != nil {
+		t.Fatal(err)
+	}
+	want = `Income statement from 2023-02-01 to 2023-03-01 (GBP at market value)
+====================================================================
+Income      -1000 GBP
+Expenses     80.0 GBP
+
+Net income  920.0 GBP
+`
+	if diff := cmp.Diff(want, b.String()); diff != "" {
+		t.Error(diff)
+	}
+
+	bs, err = l.BalanceSheet(ReportOptions{To: mar, Ccy: "USD"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := bs.Assets.Total["USD"]; got.String() != "2625.00 USD" {
+		t.Errorf("incorrect Assets: want 2625.00 USD, got %v", got)
+	}
+	if bs.Unpriced != nil {
+		t.Errorf("want nothing unpriced, got %v", bs.Unpriced)
+	}
+}
+
+func TestBalanceSheet_Converted(t *testing.T) {
+	l := loadText(t, `
+2023-01-01 open Assets:Bank
+2023-01-01 open Assets:Broker
+2023-01-01 open Equity:Opening
+2023-01-01 open Income:Job
+
+2023-01-01 * "Opening"
+  Assets:Bank  1000 GBP
+  Equity:Opening
+
+2023-01-02 * "Salary"
+  Assets:Bank  100 GBP
+  Income:Job
+
+2023-01-03 * "Bonus shares"
+  Assets:Broker  2 GOO {40 GBP}
+  Income:Job  -2 GOO {40 GBP}
+
+2023-02-01 * "Buy"
+  Assets:Broker  10 GOO {50 GBP}
+  Assets:Bank
+
+2023-02-02 price GOO 80 GBP
+`)
+	feb := time.Date(2023, time.February, 1, 0, 0, 0, 0, time.UTC)
+	mar := time.Date(2023, time.March, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		valuation   Valuation
+		assets      string
+		previous    string
+		conversions string
+	}{
+		{ValueMarket, "1560 GBP", "-260 GBP", "-300 GBP"},
+		{ValueCost, "1180 GBP", "-180 GBP", ""},
+	}
+	for _, tt := range tests {
+		bs, err := l.BalanceSheet(ReportOptions{From: feb, To: mar, Ccy: "GBP", Valuation: tt.valuation})
+		if err != nil {
+			t.Fatal(err)
+		}
+		sum := total("", bs.Assets, bs.Liabilities, bs.Equity).Total
+		if got := sum["GBP"]; !got.Number.IsZero() {
+			t.Errorf("%s: want sections to sum to zero, got %v", tt.valuation, got)
+		}
+		if got := bs.Assets.Total["GBP"]; got.String() != tt.assets {
+			t.Errorf("%s: want Assets %s, got %v", tt.valuation, tt.assets, got)
+		}
+		for name, want := range map[AccountName]string{
+			"Equity:Earnings:Previous": tt.previous,
+			"Equity:Conversions":       tt.conversions,
+		} {
+			got := ""
+			if node := bs.Equity.Find(name); node != nil {
+				got = node.Total["GBP"].String()
+			}
+			if got != want {
+				t.Errorf("%s: want %s %q, got %q", tt.valuation, name, want, got)
+			}
+		}
+	}
+}
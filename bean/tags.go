@@ -0,0 +1,154 @@
+package bean
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// Set is an unordered set of tags or links
+type Set map[string]struct{}
+
+// Add adds x to the Set
+func (s Set) Add(x string) {
+	s[x] = struct{}{}
+}
+
+// Has returns true if x is in the Set
+func (s Set) Has(x string) bool {
+	_, ok := s[x]
+	return ok
+}
+
+// Sorted returns the members of the Set in order
+func (s Set) Sorted() []string {
+	res := make([]string, 0, len(s))
+	for x := range s {
+		res = append(res, x)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// addTagOrLink adds a #tag or ^link token to the matching Set,
+// creating it if needed. It returns false for any other token.
+func addTagOrLink(tags *Set, links *Set, t Token) bool {
+	var target *Set
+	switch {
+	case t.Kind == TokenTag:
+		target = tags
+	case t.Kind == TokenLink:
+		target = links
+	default:
+		return false
+	}
+	if *target == nil {
+		*target = make(Set, 1)
+	}
+	target.Add(t.Text[1:])
+	return true
+}
+
+// pushed is a single tag or metadata key: value on a pushStack
+type pushed struct {
+	key   string    // the tag or metadata key
+	value MetaValue // only for metadata
+	pos   Pos
+}
+
+// pushStack holds the tags and metadata pushed with pushtag and pushmeta
+// in a single file, which are applied to every directive until popped
+type pushStack struct {
+	tags []pushed
+	meta []pushed
+}
+
+// update applies a pushtag, poptag, pushmeta or popmeta Directive
+func (s *pushStack) update(directive Directive) error {
+	tokens := directive.Lines[0].Tokens
+	if len(tokens) < 2 {
+		return fmt.Errorf("%s must have an argument", tokens[0].Text)
+	}
+	log.Println("pushStack", tokens[0].Text, tokens[1].Text)
+	switch dirType(tokens[0].Text) {
+	case dirPushtag, dirPoptag:
+		tag := tokens[1].Text
+		if len(tokens) != 2 || !strings.HasPrefix(tag, "#") {
+			return fmt.Errorf("%s must have a single #tag", tokens[0].Text)
+		}
+		if tokens[0].Text == string(dirPushtag) {
+			s.tags = append(s.tags, pushed{key: tag[1:], pos: directive.Pos()})
+			return nil
+		}
+		var ok bool
+		s.tags, ok = pop(s.tags, tag[1:])
+		if !ok {
+			return fmt.Errorf("poptag of tag that was not pushed: %s", tag)
+		}
+	case dirPushmeta:
+		meta := make(Meta, 1)
+		if err := meta.addLine(Line{Tokens: tokens[1:]}); err != nil {
+			return fmt.Errorf("in update: %w", err)
+		}
+		for key, value := range meta {
+			s.meta = append(s.meta, pushed{key: key, value: value, pos: directive.Pos()})
+		}
+	case dirPopmeta:
+		key, _, _ := strings.Cut(tokens[1].Text, ":")
+		var ok bool
+		s.meta, ok = pop(s.meta, key)
+		if !ok {
+			return fmt.Errorf("popmeta of key that was not pushed: %s", key)
+		}
+	}
+	return nil
+}
+
+// pop removes the last pushed with key, returning false if there isn't one
+func pop(stack []pushed, key string) ([]pushed, bool) {
+	for i := len(stack) - 1; i >= 0; i-- {
+		if stack[i].key == key {
+			return append(stack[:i], stack[i+1:]...), true
+		}
+	}
+	return stack, false
+}
+
+// applyTags adds all pushed tags to tags
+func (s *pushStack) applyTags(tags Set) Set {
+	if len(s.tags) > 0 && tags == nil {
+		tags = make(Set, len(s.tags))
+	}
+	for _, p := range s.tags {
+		tags.Add(p.key)
+	}
+	return tags
+}
+
+// applyMeta adds all pushed metadata to meta,
+// without overwriting keys that are already set.
+// The stack is walked from the top, so the latest push of a key wins.
+func (s *pushStack) applyMeta(meta Meta) Meta {
+	if len(s.meta) > 0 && meta == nil {
+		meta = make(Meta, len(s.meta))
+	}
+	for i := len(s.meta) - 1; i >= 0; i-- {
+		p := s.meta[i]
+		if _, ok := meta[p.key]; !ok {
+			meta[p.key] = p.value
+		}
+	}
+	return meta
+}
+
+// check returns an error if anything pushed was never popped
+func (s *pushStack) check() error {
+	if len(s.tags) > 0 {
+		return errorAt(s.tags[0].pos, "pushtag #%s was never popped", s.tags[0].key)
+	}
+	if len(s.meta) > 0 {
+		return errorAt(s.meta[0].pos, "pushmeta %s was never popped", s.meta[0].key)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package bean
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSet(t *testing.T) {
+	s := Set{}
+	s.Add("trip")
+	s.Add("food")
+	s.Add("trip")
+	if !s.Has("trip") || s.Has("work") {
+		t.Errorf("wrong members: %v", s)
+	}
+	if diff := cmp.Diff([]string{"food", "trip"}, s.Sorted()); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func Test_pushStack(t *testing.T) {
+	directive := func(tokens ...string) Directive {
+		return Directive{Lines: []Line{{Tokens: tokensOf(tokens...)}}}
+	}
+	s := &pushStack{}
+	for _, d := range []Directive{
+		directive("pushtag", "#trip"),
+		directive("pushtag", "#food"),
+		directive("pushmeta", "location:", "GBP"),
+		directive("poptag", "#food"),
+	} {
+		if err := s.update(d); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tags := s.applyTags(nil)
+	if diff := cmp.Diff([]string{"trip"}, tags.Sorted()); diff != "" {
+		t.Error(diff)
+	}
+	// existing keys should not be overwritten
+	meta := s.applyMeta(Meta{"location": {Kind: MetaString, Text: "here"}})
+	if meta["location"].Text != "here" {
+		t.Errorf("pushed meta should not overwrite: %v", meta)
+	}
+	if meta := s.applyMeta(nil); meta["location"].Text != "GBP" {
+		t.Errorf("pushed meta missing: %v", meta)
+	}
+
+	// a key pushed twice takes the latest value until it is popped
+	if err := s.update(directive("pushmeta", "location:", "EUR")); err != nil {
+		t.Fatal(err)
+	}
+	if meta := s.applyMeta(nil); meta["location"].Text != "EUR" {
+		t.Errorf("latest pushed meta should win: %v", meta)
+	}
+	if err := s.update(directive("popmeta", "location:")); err != nil {
+		t.Fatal(err)
+	}
+	if meta := s.applyMeta(nil); meta["location"].Text != "GBP" {
+		t.Errorf("popmeta should restore the earlier value: %v", meta)
+	}
+
+	// anything not popped is an error
+	if err := s.check(); err == nil {
+		t.Error("unbalanced pushtag should error")
+	}
+	if err := s.update(directive("poptag", "#trip")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.update(directive("popmeta", "location:")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.check(); err != nil {
+		t.Error(err)
+	}
+
+	// popping something that wasn't pushed is an error
+	if err := s.update(directive("poptag", "#trip")); err == nil {
+		t.Error("poptag without pushtag should error")
+	}
+	if err := s.update(directive("popmeta", "location:")); err == nil {
+		t.Error("popmeta without pushmeta should error")
+	}
+	if err := s.update(directive("pushtag", "trip")); err == nil {
+		t.Error("pushtag without # should error")
+	}
+}
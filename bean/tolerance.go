@@ -0,0 +1,89 @@
+package bean
+
+import (
+	"github.com/cockroachdb/apd/v3"
+)
+
+// Tolerances are how far from zero each currency
+// in a Transaction may be while still balancing
+type Tolerances map[Ccy]apd.Decimal
+
+// maxPriceTolerance caps the tolerance added by each price, as in beancount
+var maxPriceTolerance = apd.New(5, -1)
+
+// inferTolerances infers the tolerance of each currency from the Postings,
+// as in beancount: the last decimal place of the units times the
+// inferred_tolerance_multiplier (so half of it by default).
+// Postings with a price add their tolerance times the price
+// to the tolerance of the price currency.
+// Integer units don't give a tolerance, see get.
+func inferTolerances(postings []Posting, opts Options) Tolerances {
+	tols := make(Tolerances, 2)
+	priceTols := make(Tolerances)
+	for _, p := range postings {
+		if p.Amount == nil {
+			continue
+		}
+		exp := p.Amount.Number.Exponent
+		if exp >= 0 {
+			continue
+		}
+		tol := apd.Decimal{}
+		apdCtx.Mul(&tol, apd.New(1, exp), &opts.InferredToleranceMultiplier)
+		tol.Reduce(&tol)
+		if cur, ok := tols[p.Amount.Ccy]; !ok || tol.Cmp(&cur) > 0 {
+			tols[p.Amount.Ccy] = tol
+		}
+		if p.Price == nil || p.PriceTotal {
+			continue
+		}
+		priceTol := apd.Decimal{}
+		apdCtx.Mul(&priceTol, &tol, &p.Price.Number)
+		apdCtx.Abs(&priceTol, &priceTol)
+		if priceTol.Cmp(maxPriceTolerance) > 0 {
+			priceTol.Set(maxPriceTolerance)
+		}
+		sum := priceTols[p.Price.Ccy]
+		apdCtx.Add(&sum, &sum, &priceTol)
+		priceTols[p.Price.Ccy] = sum
+	}
+	for ccy, tol := range priceTols {
+		if cur, ok := tols[ccy]; !ok || tol.Cmp(&cur) > 0 {
+			tols[ccy] = tol
+		}
+	}
+	return tols
+}
+
+// get returns the tolerance for ccy, falling back on the
+// inferred_tolerance_default for ccy, then for *, then zero
+func (t Tolerances) get(ccy Ccy, opts Options) apd.Decimal {
+	if tol, ok := t[ccy]; ok {
+		return tol
+	}
+	if tol, ok := opts.InferredToleranceDefault[ccy]; ok {
+		return tol
+	}
+	return opts.InferredToleranceDefault["*"]
+}
+
+// balanceTolerance returns the explicit tolerance of the Balance,
+// or else one inferred from its number of decimal places
+// (the multiplier is doubled, so 100.00 GBP gives 0.01 by default)
+func balanceTolerance(b Balance, opts Options) apd.Decimal {
+	if b.Tolerance != nil {
+		return *b.Tolerance
+	}
+	tol := apd.Decimal{}
+	if exp := b.Amount.Number.Exponent; exp < 0 {
+		apdCtx.Mul(&tol, apd.New(2, exp), &opts.InferredToleranceMultiplier)
+		tol.Reduce(&tol)
+	}
+	return tol
+}
+
+// within returns true if the absolute value of amt is at most tol
+func within(amt Amount, tol apd.Decimal) bool {
+	abs := amt.Abs()
+	return abs.Number.Cmp(&tol) <= 0
+}
@@ -0,0 +1,53 @@
+package bean
+
+import (
+	"testing"
+
+	"github.com/cockroachdb/apd/v3"
+)
+
+func Test_inferTolerances(t *testing.T) {
+	price := MustNewAmount("1.17", "EUR")
+	postings := []Posting{
+		{Amount: amtPtr("-33.33", "GBP"), Price: &price},
+		{Amount: amtPtr("38.9", "EUR")},
+		{Amount: amtPtr("10", "USD")},
+	}
+	opts := defaultOptions()
+	tols := inferTolerances(postings, opts)
+	tests := map[Ccy]string{
+		"GBP": "0.005",
+		"EUR": "0.05", // from 38.9, bigger than 0.005 * 1.17
+		"USD": "0",    // integers have no tolerance
+		"JPY": "0",    // unknown currencies use the default
+	}
+	for ccy, want := range tests {
+		tol := tols.get(ccy, opts)
+		if tol.Text('f') != want && !(want == "0" && tol.IsZero()) {
+			t.Errorf("%s: want %s, got %s", ccy, want, tol.Text('f'))
+		}
+	}
+
+	// defaults and multiplier from the options
+	opts.InferredToleranceDefault["*"] = *apd.New(1, -1)
+	opts.InferredToleranceDefault["USD"] = *apd.New(1, 0)
+	opts.InferredToleranceMultiplier = *apd.New(1, 0)
+	tols = inferTolerances(postings, opts)
+	for ccy, want := range map[Ccy]string{"GBP": "0.01", "USD": "1", "JPY": "0.1"} {
+		if tol := tols.get(ccy, opts); tol.Text('f') != want {
+			t.Errorf("%s: want %s, got %s", ccy, want, tol.Text('f'))
+		}
+	}
+}
+
+func Test_balanceTolerance(t *testing.T) {
+	opts := defaultOptions()
+	b := Balance{Amount: MustNewAmount("100.00", "GBP")}
+	if tol := balanceTolerance(b, opts); tol.Text('f') != "0.01" {
+		t.Errorf("want 0.01, got %s", tol.Text('f'))
+	}
+	b.Tolerance = apd.New(5, -1)
+	if tol := balanceTolerance(b, opts); tol.Text('f') != "0.5" {
+		t.Errorf("want 0.5, got %s", tol.Text('f'))
+	}
+}
@@ -1,6 +1,7 @@
 package bean
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"time"
@@ -12,7 +13,11 @@ type Transaction struct {
 	Type      string
 	Payee     string
 	Narration string
+	Tags      Set // nil if there are none
+	Links     Set // nil if there are none
 	Postings  []Posting
+	Meta      Meta
+	Pos       Pos
 }
 
 func (t Transaction) String() string {
@@ -28,7 +33,9 @@ func (t Transaction) String() string {
 func newTransaction(directive Directive) (Transaction, error) {
 	// first line is the root transaction line
 	rootLine := directive.Lines[0]
-	log.Println("newTransaction", rootLine.Tokens[0].Text)
+	if debug {
+		log.Println("newTransaction", rootLine.Tokens[0].Text)
+	}
 	tokens := rootLine.Tokens
 	date, err := getDate(tokens[0].Text)
 	if err != nil {
@@ -39,19 +46,50 @@ func newTransaction(directive Directive) (Transaction, error) {
 	// If there is only one text, it is the narration
 	// if there are two, first is payee, second is narration.
 	// Dont ask me, I didn't design beancount!
-	narration := tokens[2].Text
-	var payee string
-	if len(tokens) >= 4 {
-		if tokens[3].Quote {
-			payee = narration
-			narration = tokens[3].Text
+	// Any #tags and ^links come after the texts.
+	var payee, narration string
+	var tags, links Set
+	texts := 0
+	for _, t := range tokens[2:] {
+		if t.Kind == TokenString && tags == nil && links == nil {
+			if texts == 2 {
+				return Transaction{}, fmt.Errorf("transaction can only have a payee and narration: %s", rootLine)
+			}
+			payee, narration = narration, t.Text
+			texts++
+		} else if !addTagOrLink(&tags, &links, t) {
+			return Transaction{}, fmt.Errorf("unexpected token in transaction: %s", t.Text)
 		}
 	}
 
-	var postings []Posting
+	// metadata lines belong to the transaction until the
+	// first posting, and after that to the preceding posting
+	postings := make([]Posting, 0, len(directive.Lines)-1)
+	var meta Meta
 	for _, line := range directive.Lines[1:] {
-		// newPosting doesnt error currently
-		p, _ := newPosting(line)
+		if isTagLine(line) {
+			for _, t := range line.Tokens {
+				addTagOrLink(&tags, &links, t)
+			}
+			continue
+		}
+		if isMetaLine(line) {
+			target := &meta
+			if len(postings) > 0 {
+				target = &postings[len(postings)-1].Meta
+			}
+			if *target == nil {
+				*target = make(Meta, 1)
+			}
+			if err := target.addLine(line); err != nil {
+				return Transaction{}, fmt.Errorf("in newTransaction: %w", err)
+			}
+			continue
+		}
+		p, err := newPosting(line)
+		if err != nil {
+			return Transaction{}, fmt.Errorf("in newTransaction: %w", err)
+		}
 		postings = append(postings, p)
 	}
 
@@ -60,65 +98,109 @@ func newTransaction(directive Directive) (Transaction, error) {
 		Type:      txType,
 		Payee:     payee,
 		Narration: narration,
+		Tags:      tags,
+		Links:     links,
 		Postings:  postings,
+		Meta:      meta,
+		Pos:       directive.Pos(),
 	}
 	return transaction, nil
 }
 
-// balanceTransaction checks that a Transaction balances for all ccys
+// isTagLine returns true if the (indented) line is only #tags and ^links
+func isTagLine(line Line) bool {
+	for _, t := range line.Tokens {
+		var tags, links Set
+		if !addTagOrLink(&tags, &links, t) {
+			return false
+		}
+	}
+	return true
+}
+
+// balanceTransaction checks that a Transaction balances for all ccys,
+// using the Weight of each Posting.
 // The Posting _without_ an Amount (max one) will be used to auto-balance
 // any currencies that dont already balance.
-func balanceTransaction(transaction Transaction) (Transaction, error) {
-	log.Println("Balancing", transaction.Date.Format(time.DateOnly), transaction.Narration)
+func balanceTransaction(transaction Transaction, opts Options) (Transaction, error) {
+	if debug {
+		log.Println("Balancing", transaction.Date.Format(time.DateOnly), transaction.Narration)
+	}
 	ccyBalances := make(CcyAmount, 3)
+	ccyOrder := make([]Ccy, 0, 3)
 	postings := make([]Posting, 0, len(transaction.Postings))
 	emptyPostingIndex := -1
 	for i, p := range transaction.Postings {
-		log.Printf("  Posting %v", p.String())
+		if debug {
+			log.Printf("  Posting %v", p)
+		}
 		if p.Amount == nil {
 			if emptyPostingIndex != -1 {
-				return Transaction{}, fmt.Errorf("cannot have multiple empty postings: %s", transaction)
+				return Transaction{}, fmt.Errorf("cannot have multiple empty postings")
 			}
 			emptyPostingIndex = i
 		} else {
-			curVal, ok := ccyBalances[p.Amount.Ccy]
+			weight := p.Weight()
+			curVal, ok := ccyBalances[weight.Ccy]
 			if ok {
-				ccyBalances[p.Amount.Ccy] = curVal.MustAdd(*p.Amount)
+				ccyBalances[weight.Ccy] = curVal.MustAdd(weight)
 			} else {
-				ccyBalances[p.Amount.Ccy] = *p.Amount
+				ccyBalances[weight.Ccy] = weight
+				ccyOrder = append(ccyOrder, weight.Ccy)
 			}
 			postings = append(postings, p)
 		}
 	}
 	if emptyPostingIndex != -1 {
-		log.Println("found empty postings!")
+		if debug {
+			log.Println("found empty postings!")
+		}
 		// note that we dont actually use the empty posting, just its account
 		// because we will need more than 1 if there are multiple unbalanced ccys
 		// and this makes the logic slightly easier
 		account := transaction.Postings[emptyPostingIndex].Account
-		for _, num := range ccyBalances {
+		for _, ccy := range ccyOrder {
+			num := ccyBalances[ccy]
+			if num.Number.IsZero() {
+				continue
+			}
 			neg := num.Neg()
 			p := Posting{
 				Account: account,
 				Amount:  &neg,
 			}
-			log.Printf("  new posting %v", p.String())
+			if debug {
+				log.Printf("  new posting %v", p)
+			}
 			postings = append(postings, p)
 		}
+	} else {
+		tols := inferTolerances(transaction.Postings, opts)
+		for _, ccy := range ccyOrder {
+			residual := ccyBalances[ccy]
+			if tol := tols.get(ccy, opts); !within(residual, tol) {
+				return Transaction{}, fmt.Errorf("transaction does not balance: residual %v exceeds tolerance %s %s",
+					residual, tol.Text('f'), ccy)
+			}
+		}
 	}
 	transaction.Postings = postings
 	return transaction, nil
 }
 
 // balanceTransactions balances all Transactions and returns the new
-// balanced Transactions (original not modified)
-func balanceTransactions(transactions []Transaction) ([]Transaction, error) {
+// balanced Transactions (original not modified).
+// Transactions that don't balance are left as they are,
+// and all the errors are returned.
+func balanceTransactions(transactions []Transaction, opts Options) ([]Transaction, error) {
+	var errs []error
 	for i, tx := range transactions {
-		transaction, err := balanceTransaction(tx)
+		transaction, err := balanceTransaction(tx, opts)
 		if err != nil {
-			return nil, fmt.Errorf("in balanceTransactions: %w", err)
+			errs = append(errs, errorFrom(tx.Pos, err))
+			continue
 		}
 		transactions[i] = transaction
 	}
-	return transactions, nil
+	return transactions, errors.Join(errs...)
 }
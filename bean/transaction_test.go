@@ -26,9 +26,101 @@ func Test_balanceTransaction(t *testing.T) {
 			},
 		},
 	}
-	got, _ := balanceTransaction(want)
+	got, _ := balanceTransaction(want, defaultOptions())
 
 	if diff := cmp.Diff(want, got, cmp.AllowUnexported(apd.BigInt{})); diff != "" {
 		t.Error(diff)
 	}
 }
+
+func Test_balanceTransaction_weights(t *testing.T) {
+	units := MustNewAmount("10", "GOO")
+	price := MustNewAmount("1.2", "EUR")
+	cash := MustNewAmount("-12.0", "EUR")
+	tx := Transaction{
+		Date: time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC),
+		Postings: []Posting{
+			{Account: Account{"Assets:Invest"}, Amount: &units, Price: &price},
+			{Account: Account{"Assets:Bank"}, Amount: &cash},
+		},
+	}
+
+	// price should be used to balance
+	if _, err := balanceTransaction(tx, defaultOptions()); err != nil {
+		t.Error(err)
+	}
+
+	// empty posting should be filled from the weight
+	tx.Postings[1].Amount = nil
+	got, _ := balanceTransaction(tx, defaultOptions())
+	if want := MustNewAmount("-12", "EUR"); !got.Postings[1].Amount.Eq(want) {
+		t.Errorf("want %v, got %v", want, got.Postings[1].Amount)
+	}
+
+	// unbalanced transaction should error
+	tx.Postings[0].Price = nil
+	tx.Postings[1].Amount = &cash
+	if _, err := balanceTransaction(tx, defaultOptions()); err == nil {
+		t.Error("unbalanced transaction should error")
+	}
+}
+
+func Test_newTransaction_meta(t *testing.T) {
+	// metadata should attach to the transaction or the preceding posting
+	directive := Directive{Lines: []Line{
+		{Tokens: tokensOf("2023-01-01", "*", "Shop")},
+		{Tokens: tokensOf("trip:", "TRUE")},
+		{Tokens: tokensOf("Assets:Bank", "-10", "GBP")},
+		{Tokens: tokensOf("receipt:", "scan.pdf")},
+		{Tokens: tokensOf("Expenses:Food")},
+	}}
+	directive.Lines[0].Tokens[2].Kind = TokenString
+	directive.Lines[3].Tokens[1].Kind = TokenString
+	got, err := newTransaction(directive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Meta["trip"].Bool {
+		t.Errorf("transaction meta missing: %v", got.Meta)
+	}
+	if got.Postings[0].Meta["receipt"].Text != "scan.pdf" {
+		t.Errorf("posting meta missing: %v", got.Postings[0].Meta)
+	}
+	if got.Postings[1].Meta != nil {
+		t.Errorf("posting should have no meta: %v", got.Postings[1].Meta)
+	}
+}
+
+func Test_newTransaction_tags(t *testing.T) {
+	// tags and links can follow the texts and be on their own lines
+	directive := Directive{Lines: []Line{
+		{Tokens: tokensOf("2023-01-01", "*", "Shop", "Food", "#trip", "^receipt-1")},
+		{Tokens: tokensOf("#food", "^receipt-2")},
+		{Tokens: tokensOf("Assets:Bank", "-10", "GBP")},
+		{Tokens: tokensOf("Expenses:Food")},
+	}}
+	directive.Lines[0].Tokens[2].Kind = TokenString
+	directive.Lines[0].Tokens[3].Kind = TokenString
+	got, err := newTransaction(directive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Payee != "Shop" || got.Narration != "Food" {
+		t.Errorf("wrong payee/narration: %q %q", got.Payee, got.Narration)
+	}
+	if diff := cmp.Diff([]string{"food", "trip"}, got.Tags.Sorted()); diff != "" {
+		t.Error(diff)
+	}
+	if diff := cmp.Diff([]string{"receipt-1", "receipt-2"}, got.Links.Sorted()); diff != "" {
+		t.Error(diff)
+	}
+	if len(got.Postings) != 2 {
+		t.Errorf("want 2 postings, got %d", len(got.Postings))
+	}
+
+	// texts after a tag are an error
+	directive.Lines[0].Tokens = append(directive.Lines[0].Tokens, Token{Kind: TokenString, Text: "late"})
+	if _, err := newTransaction(directive); err == nil {
+		t.Error("text after tags should error")
+	}
+}
@@ -0,0 +1,207 @@
+package bean
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AccountTree is a node in the account hierarchy.
+// The root has an empty Name, and its Children are the root accounts.
+type AccountTree struct {
+	Name     AccountName    `json:"name"`
+	Balance  CcyAmount      `json:"balance"`            // of the account itself
+	Total    CcyAmount      `json:"total"`              // including all descendants
+	Children []*AccountTree `json:"children,omitempty"` // sorted by name
+}
+
+// NewAccountTree builds an AccountTree from account balances,
+// adding parent accounts as needed and rolling balances up into them
+func NewAccountTree(bals AccBal) *AccountTree {
+	root := &AccountTree{Balance: CcyAmount{}, Total: CcyAmount{}}
+	nodes := map[AccountName]*AccountTree{"": root}
+	var node func(name AccountName) *AccountTree
+	node = func(name AccountName) *AccountTree {
+		if n, ok := nodes[name]; ok {
+			return n
+		}
+		n := &AccountTree{Name: name, Balance: CcyAmount{}, Total: CcyAmount{}}
+		nodes[name] = n
+		parent := node(parentAccount(name))
+		parent.Children = append(parent.Children, n)
+		return n
+	}
+	for acc, ca := range bals {
+		n := node(acc)
+		for _, amt := range ca {
+			addToCcyAmount(n.Balance, amt)
+		}
+	}
+	root.sum()
+	return root
+}
+
+// sum sorts the children and sets the Total of every node
+func (t *AccountTree) sum() {
+	sort.Slice(t.Children, func(i, j int) bool { return t.Children[i].Name < t.Children[j].Name })
+	for _, amt := range t.Balance {
+		addToCcyAmount(t.Total, amt)
+	}
+	for _, child := range t.Children {
+		child.sum()
+		for _, amt := range child.Total {
+			addToCcyAmount(t.Total, amt)
+		}
+	}
+}
+
+// parentAccount returns the parent of name, or "" for root accounts
+func parentAccount(name AccountName) AccountName {
+	i := strings.LastIndexByte(string(name), ':')
+	if i < 0 {
+		return ""
+	}
+	return name[:i]
+}
+
+// Leaf returns the last component of the account name
+func (t *AccountTree) Leaf() string {
+	return string(t.Name[strings.LastIndexByte(string(t.Name), ':')+1:])
+}
+
+// Find returns the node for name, or nil if it is not in the tree
+func (t *AccountTree) Find(name AccountName) *AccountTree {
+	if t.Name == name {
+		return t
+	}
+	for _, child := range t.Children {
+		if isSubAccount(name, child.Name) {
+			return child.Find(name)
+		}
+	}
+	return nil
+}
+
+// Truncate returns a copy of the tree with at most depth levels below the root
+// (1 for root accounts only), with deeper balances rolled up into their
+// ancestors at the last level. Zero or less means no truncation.
+func (t *AccountTree) Truncate(depth int) *AccountTree {
+	res := *t
+	if depth <= 0 {
+		return &res
+	}
+	if depth == 1 && t.Name != "" {
+		res.Balance = t.Total
+		res.Children = nil
+		return &res
+	}
+	if t.Name != "" {
+		depth--
+	}
+	res.Children = make([]*AccountTree, len(t.Children))
+	for i, child := range t.Children {
+		res.Children[i] = child.Truncate(depth)
+	}
+	return &res
+}
+
+// Walk calls fn for every node below the root in sorted depth-first order,
+// with depth 1 for root accounts, stopping at the first error
+func (t *AccountTree) Walk(fn func(node *AccountTree, depth int) error) error {
+	return t.walk(fn, 0)
+}
+
+func (t *AccountTree) walk(fn func(node *AccountTree, depth int) error, depth int) error {
+	if t.Name != "" {
+		if err := fn(t, depth); err != nil {
+			return err
+		}
+	}
+	for _, child := range t.Children {
+		if err := child.walk(fn, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AccountTree returns the balances of all accounts at the start of date
+// as an AccountTree, with root accounts in the order from the Options
+func (l *Ledger) AccountTree(date time.Time) (*AccountTree, error) {
+	bals, err := l.GetBalances(date)
+	if err != nil {
+		return nil, fmt.Errorf("in AccountTree: %w", err)
+	}
+	return l.Tree(bals), nil
+}
+
+// Tree builds an AccountTree from bals,
+// with root accounts in the order from the Options
+func (l *Ledger) Tree(bals AccBal) *AccountTree {
+	tree := NewAccountTree(bals)
+	order := make(map[AccountName]int)
+	for i, root := range l.Options.RootNames() {
+		order[AccountName(root)] = i
+	}
+	sort.SliceStable(tree.Children, func(i, j int) bool {
+		return order[tree.Children[i].Name] < order[tree.Children[j].Name]
+	})
+	return tree
+}
+
+// PrintAccountTree writes the tree to w, indented by depth,
+// with the Total of each account in aligned columns (one line per currency)
+func PrintAccountTree(w io.Writer, tree *AccountTree) error {
+	return printAccountTree(w, tree)
+}
+
+// printAccountTree is PrintAccountTree followed by a blank line and
+// the totals, labelled with their Name and aligned with the tree
+func printAccountTree(w io.Writer, tree *AccountTree, totals ...*AccountTree) error {
+	nameWidth, numWidth := 0, 0
+	measure := func(node *AccountTree, depth int) error {
+		nameWidth = max(nameWidth, 2*(depth-1)+len(node.Leaf()))
+		for _, amt := range node.Total {
+			numWidth = max(numWidth, len(amt.Number.Text('f')))
+		}
+		return nil
+	}
+	tree.Walk(measure)
+	for _, total := range totals {
+		measure(total, 1)
+	}
+	line := func(name string, ca CcyAmount) error {
+		ccys := sortedCcys(ca)
+		if len(ccys) == 0 {
+			_, err := fmt.Fprintln(w, name)
+			return err
+		}
+		for i, ccy := range ccys {
+			if i > 0 {
+				name = ""
+			}
+			amt := ca[ccy]
+			if _, err := fmt.Fprintf(w, "%-*s  %*s %s\n", nameWidth, name, numWidth, amt.Number.Text('f'), ccy); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	err := tree.Walk(func(node *AccountTree, depth int) error {
+		return line(strings.Repeat("  ", depth-1)+node.Leaf(), node.Total)
+	})
+	if err != nil || len(totals) == 0 {
+		return err
+	}
+	if _, err := fmt.Fprintln(w); err != nil {
+		return err
+	}
+	for _, total := range totals {
+		if err := line(string(total.Name), total.Total); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package bean
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func testTree() *AccountTree {
+	return NewAccountTree(AccBal{
+		"Expenses:Food:Shop": MustNewCcyAmount(map[string]string{"GBP": "10"}),
+		"Expenses:Food":      MustNewCcyAmount(map[string]string{"GBP": "5"}),
+		"Expenses:Rent":      MustNewCcyAmount(map[string]string{"GBP": "100", "USD": "1"}),
+		"Assets:Bank":        MustNewCcyAmount(map[string]string{"GBP": "-115"}),
+	})
+}
+
+func TestNewAccountTree(t *testing.T) {
+	tree := testTree()
+	var got []string
+	tree.Walk(func(node *AccountTree, depth int) error {
+		got = append(got, strings.Repeat(" ", depth)+string(node.Name)+" "+node.Total["GBP"].String())
+		return nil
+	})
+	want := []string{
+		" Assets -115 GBP",
+		"  Assets:Bank -115 GBP",
+		" Expenses 115 GBP",
+		"  Expenses:Food 15 GBP",
+		"   Expenses:Food:Shop 10 GBP",
+		"  Expenses:Rent 100 GBP",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Error(diff)
+	}
+	if got := tree.Find("Expenses:Food").Balance["GBP"]; got.String() != "5 GBP" {
+		t.Errorf("own balance should not include children: %v", got)
+	}
+	if tree.Find("Expenses:Nope") != nil {
+		t.Error("should not find missing account")
+	}
+	if got := tree.Total["GBP"]; !got.Number.IsZero() {
+		t.Errorf("root should total everything: %v", got)
+	}
+}
+
+func TestAccountTree_Truncate(t *testing.T) {
+	tree := testTree()
+	truncated := tree.Truncate(2)
+	food := truncated.Find("Expenses:Food")
+	if len(food.Children) != 0 || food.Balance["GBP"].String() != "15 GBP" {
+		t.Errorf("children should be rolled up: %v", food)
+	}
+	if len(tree.Find("Expenses:Food").Children) != 1 {
+		t.Error("original tree should be unchanged")
+	}
+	if got := len(tree.Truncate(1).Find("Expenses").Children); got != 0 {
+		t.Errorf("depth 1 should only have roots, got %d children", got)
+	}
+}
+
+func TestPrintAccountTree(t *testing.T) {
+	var b strings.Builder
+	if err := PrintAccountTree(&b, testTree().Truncate(2)); err != nil {
+		t.Fatal(err)
+	}
+	want := `Assets    -115 GBP
+  Bank    -115 GBP
+Expenses   115 GBP
+             1 USD
+  Food      15 GBP
+  Rent     100 GBP
+             1 USD
+`
+	if diff := cmp.Diff(want, b.String()); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestAccountTree_JSON(t *testing.T) {
+	got, err := json.Marshal(testTree().Truncate(1).Find("Expenses"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"name":"Expenses","balance":{"GBP":{"number":"115","currency":"GBP"},"USD":{"number":"1","currency":"USD"}},"total":{"GBP":{"number":"115","currency":"GBP"},"USD":{"number":"1","currency":"USD"}}}`
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Error(diff)
+	}
+}
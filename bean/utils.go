@@ -3,17 +3,40 @@ package bean
 import (
 	"fmt"
 	"log"
+	"os"
+	"strings"
 	"time"
 )
 
+// getDate parses a YYYY-MM-DD or YYYY/MM/DD date
 func getDate(dateStr string) (time.Time, error) {
-	date, err := time.Parse(time.DateOnly, dateStr)
+	// fast path for anything the lexer calls a date
+	if isDate(dateStr) {
+		year := atoi(dateStr[0:4])
+		month := atoi(dateStr[5:7])
+		day := atoi(dateStr[8:10])
+		date := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+		// time.Date normalises invalid dates like 2023-02-30
+		if date.Day() == day && int(date.Month()) == month {
+			return date, nil
+		}
+	}
+	date, err := time.Parse(time.DateOnly, strings.ReplaceAll(dateStr, "/", "-"))
 	if err != nil {
 		return time.Time{}, fmt.Errorf("in getDate: %w", err)
 	}
 	return date, nil
 }
 
+// atoi converts a string of ASCII digits to an int
+func atoi(s string) int {
+	n := 0
+	for i := 0; i < len(s); i++ {
+		n = n*10 + int(s[i]-'0')
+	}
+	return n
+}
+
 func debugSlice[T fmt.Stringer](els []T, msg string) {
 	if debug {
 		fmt.Println("--", msg)
@@ -26,13 +49,9 @@ func debugSlice[T fmt.Stringer](els []T, msg string) {
 }
 
 // PrintAccBalances pretty prints account balances by currency
+// as an indented AccountTree, with parents totalling their children
 func PrintAccBalances(accBalances AccBal) {
-	for acc, bals := range accBalances {
-		fmt.Println(acc)
-		for ccy, amt := range bals {
-			fmt.Printf("  %s %s\n", amt.Number.Text('f'), ccy)
-		}
-	}
+	PrintAccountTree(os.Stdout, NewAccountTree(accBalances))
 }
 
 func debugTokens(tokens []Token) {
@@ -1,10 +1,21 @@
 package bean
 
 import (
+	"io"
+	"strings"
 	"testing"
 	"time"
 )
 
+// loadText loads a Ledger from beancount text and fails on any error
+func loadText(t *testing.T, text string) *Ledger {
+	l, err := NewLedger(false).Load(io.NopCloser(strings.NewReader(text)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
 func TestGetDate(t *testing.T) {
 	got, _ := getDate("2022-01-01")
 	want := time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC)
@@ -12,9 +23,15 @@ func TestGetDate(t *testing.T) {
 		t.Errorf("incorrect result: want %s, got %s", want, got)
 	}
 
-	got, err := getDate("invalid")
-	if err == nil {
-		t.Errorf("incorrect result: expected error")
+	got, _ = getDate("2022/01/01")
+	if got != want {
+		t.Errorf("slashes: want %s, got %s", want, got)
+	}
+
+	for _, invalid := range []string{"invalid", "2022-02-30", "2022/02/30"} {
+		if _, err := getDate(invalid); err == nil {
+			t.Errorf("incorrect result: expected error for %s", invalid)
+		}
 	}
 }
 
@@ -2,14 +2,19 @@
 package cmd
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/carderne/gobean/api"
 	"github.com/carderne/gobean/bean"
+	"github.com/carderne/gobean/printer"
+	"github.com/carderne/gobean/query"
 	"github.com/urfave/cli/v2"
 )
 
@@ -46,6 +51,12 @@ func Cmd() {
 				Name:    "balances",
 				Aliases: []string{"b"},
 				Usage:   "Print all account balances",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{Name: "auto-open", Usage: "open accounts on first use"},
+					&cli.IntFlag{Name: "depth", Usage: "only show this many levels of accounts"},
+					&cli.StringFlag{Name: "convert", Aliases: []string{"c"}, Usage: "convert to this currency (default the operating currency)"},
+					&cli.StringFlag{Name: "value", Usage: "valuation: units, cost or market", Value: "units"},
+				},
 				Action: func(cCtx *cli.Context) error {
 					defer func() {
 						if v := recover(); v != nil {
@@ -57,22 +68,254 @@ func Cmd() {
 						}
 					}()
 					path := cCtx.Args().First()
-					file, err := os.Open(path)
+					ledger := bean.NewLedger(debug)
+					ledger.Options.AutoOpen = cCtx.Bool("auto-open")
+					res := ledger.LoadFileAll(path)
+					printErrors(os.Stderr, res.Errors)
+					if res.HasErrors() {
+						return cli.Exit("", 1)
+					}
+					valuation, err := bean.NewValuation(cCtx.String("value"))
+					if err != nil {
+						return cli.Exit(err, 1)
+					}
+					ccy := bean.Ccy(cCtx.String("convert"))
+					if ccy == "" && len(ledger.Options.OperatingCurrencies) > 0 {
+						ccy = ledger.Options.OperatingCurrencies[0]
+					}
+					if cCtx.IsSet("convert") && !cCtx.IsSet("value") {
+						valuation = bean.ValueMarket
+					}
+					if valuation != bean.ValueUnits && ccy == "" {
+						return cli.Exit("no currency to convert to: use --convert or an operating_currency option", 1)
+					}
+					date := time.Now()
+					bals, err := ledger.GetBalancesIn(date, ccy, valuation)
 					if err != nil {
 						panic(err)
 					}
-					defer file.Close()
+					depth := cCtx.Int("depth")
+					if err := bean.PrintAccountTree(os.Stdout, ledger.Tree(bals.Balances).Truncate(depth)); err != nil {
+						return err
+					}
+					if len(bals.Unpriced) > 0 {
+						fmt.Printf("\nUnpriced (no %s price):\n", ccy)
+						return bean.PrintAccountTree(os.Stdout, ledger.Tree(bals.Unpriced).Truncate(depth))
+					}
+					return nil
+				},
+			},
+			{
+				Name:      "check",
+				Aliases:   []string{"c"},
+				Usage:     "Check a beancount file and print any errors",
+				ArgsUsage: "FILE",
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "format", Usage: "output format: text or json", Value: "text"},
+				},
+				Action: func(cCtx *cli.Context) error {
+					path := cCtx.Args().First()
+					if len(path) == 0 {
+						return cli.Exit("Must provide a filepath as the first arg", 1)
+					}
+					format := cCtx.String("format")
+					if format != "text" && format != "json" {
+						return cli.Exit("format must be text or json", 1)
+					}
 					ledger := bean.NewLedger(debug)
-					_, err = ledger.Load(file)
+					res := ledger.LoadFileAll(path)
+					if format == "json" {
+						errs := res.Errors
+						if errs == nil {
+							errs = []bean.Error{}
+						}
+						if err := printJSON(errs); err != nil {
+							return err
+						}
+					} else {
+						printErrors(os.Stdout, res.Errors)
+					}
+					if res.HasErrors() {
+						return cli.Exit("", 1)
+					}
+					return nil
+				},
+			},
+			{
+				Name:      "format",
+				Aliases:   []string{"f"},
+				Usage:     "Format a beancount file, aligning amounts",
+				ArgsUsage: "FILE",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{Name: "write", Aliases: []string{"w"}, Usage: "write the result to the file instead of stdout"},
+					&cli.IntFlag{Name: "column", Aliases: []string{"c"}, Usage: "column of the decimal point (default fits the widest line)"},
+				},
+				Action: func(cCtx *cli.Context) error {
+					path := cCtx.Args().First()
+					if len(path) == 0 {
+						return cli.Exit("Must provide a filepath as the first arg", 1)
+					}
+					src, err := os.ReadFile(path)
 					if err != nil {
-						panic(err)
+						return cli.Exit(err, 1)
 					}
-					date := time.Now()
-					bals, err := ledger.GetBalances(date)
+					p := printer.New()
+					p.Column = cCtx.Int("column")
+					var out bytes.Buffer
+					if err := p.Format(&out, src); err != nil {
+						return cli.Exit(err, 1)
+					}
+					if !cCtx.Bool("write") {
+						_, err := os.Stdout.Write(out.Bytes())
+						return err
+					}
+					if bytes.Equal(src, out.Bytes()) {
+						return nil
+					}
+					info, err := os.Stat(path)
 					if err != nil {
-						panic(err)
+						return cli.Exit(err, 1)
+					}
+					return os.WriteFile(path, out.Bytes(), info.Mode())
+				},
+			},
+			{
+				Name:      "balance-sheet",
+				Aliases:   []string{"bs"},
+				Usage:     "Print the balance sheet, with net income in equity",
+				ArgsUsage: "FILE",
+				Flags: append(reportFlags(),
+					&cli.TimestampFlag{Name: "date", Layout: time.DateOnly, Usage: "balances at the start of this date (default now)"},
+					&cli.TimestampFlag{Name: "from", Layout: time.DateOnly, Usage: "start of the current period, earlier income is previous earnings"},
+				),
+				Action: func(cCtx *cli.Context) error {
+					ledger, opts, err := loadReport(cCtx)
+					if err != nil {
+						return err
+					}
+					if t := cCtx.Timestamp("date"); t != nil {
+						opts.To = *t
+					}
+					bs, err := ledger.BalanceSheet(opts)
+					if err != nil {
+						return cli.Exit(err, 1)
+					}
+					if cCtx.String("format") == "json" {
+						return printJSON(bs)
+					}
+					return bean.PrintBalanceSheet(os.Stdout, bs, cCtx.Int("depth"))
+				},
+			},
+			{
+				Name:      "income-statement",
+				Aliases:   []string{"is"},
+				Usage:     "Print the income statement for a period",
+				ArgsUsage: "FILE",
+				Flags: append(reportFlags(),
+					&cli.TimestampFlag{Name: "from", Layout: time.DateOnly, Usage: "start date (inclusive, default the first transaction)"},
+					&cli.TimestampFlag{Name: "to", Layout: time.DateOnly, Usage: "end date (exclusive, default now)"},
+				),
+				Action: func(cCtx *cli.Context) error {
+					ledger, opts, err := loadReport(cCtx)
+					if err != nil {
+						return err
+					}
+					if t := cCtx.Timestamp("to"); t != nil {
+						opts.To = *t
+					}
+					is, err := ledger.IncomeStatement(opts)
+					if err != nil {
+						return cli.Exit(err, 1)
+					}
+					if cCtx.String("format") == "json" {
+						return printJSON(is)
+					}
+					return bean.PrintIncomeStatement(os.Stdout, is, cCtx.Int("depth"))
+				},
+			},
+			{
+				Name:      "register",
+				Aliases:   []string{"r"},
+				Usage:     "Print the postings to an account with a running balance",
+				ArgsUsage: "FILE ACCOUNT",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{Name: "sub", Aliases: []string{"s"}, Usage: "include sub-accounts"},
+					&cli.TimestampFlag{Name: "from", Layout: time.DateOnly, Usage: "start date (inclusive)"},
+					&cli.TimestampFlag{Name: "to", Layout: time.DateOnly, Usage: "end date (exclusive)"},
+					&cli.StringFlag{Name: "format", Usage: "output format: text or json", Value: "text"},
+				},
+				Action: func(cCtx *cli.Context) error {
+					path, account := cCtx.Args().Get(0), cCtx.Args().Get(1)
+					if len(path) == 0 || len(account) == 0 {
+						return cli.Exit("Must provide a filepath and an account", 1)
+					}
+					format := cCtx.String("format")
+					if format != "text" && format != "json" {
+						return cli.Exit("format must be text or json", 1)
+					}
+					ledger := bean.NewLedger(debug)
+					res := ledger.LoadFileAll(path)
+					printErrors(os.Stderr, res.Errors)
+					if res.HasErrors() {
+						return cli.Exit("", 1)
+					}
+					var from, to time.Time
+					if t := cCtx.Timestamp("from"); t != nil {
+						from = *t
+					}
+					if t := cCtx.Timestamp("to"); t != nil {
+						to = *t
+					}
+					journal, err := ledger.Journal(bean.AccountName(account), from, to, cCtx.Bool("sub"))
+					if err != nil {
+						return cli.Exit(err, 1)
+					}
+					if format == "json" {
+						return printJSON(journal)
+					}
+					return bean.PrintJournal(os.Stdout, journal)
+				},
+			},
+			{
+				Name:      "query",
+				Aliases:   []string{"q"},
+				Usage:     "Run a query, or start an interactive shell if there isn't one",
+				ArgsUsage: "FILE [QUERY]",
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "format", Aliases: []string{"f"}, Usage: "output format: table, csv or json", Value: "table"},
+					&cli.StringFlag{Name: "name", Aliases: []string{"n"}, Usage: "run the query directive with this name"},
+					&cli.BoolFlag{Name: "list", Aliases: []string{"l"}, Usage: "list the query directives"},
+				},
+				Action: func(cCtx *cli.Context) error {
+					path := cCtx.Args().First()
+					if len(path) == 0 {
+						return cli.Exit("Must provide a filepath as the first arg", 1)
+					}
+					format, err := query.NewFormat(cCtx.String("format"))
+					if err != nil {
+						return cli.Exit(err, 1)
+					}
+					ledger, err := loadForQuery(path)
+					if err != nil {
+						return cli.Exit(err, 1)
+					}
+					switch {
+					case cCtx.Bool("list"):
+						printQueries(os.Stdout, ledger)
+					case cCtx.IsSet("name"):
+						err = runNamed(os.Stdout, ledger, cCtx.String("name"), format)
+					case cCtx.Args().Len() > 1:
+						var res *query.Result
+						res, err = query.Run(ledger, strings.Join(cCtx.Args().Slice()[1:], " "))
+						if err == nil {
+							err = query.Print(os.Stdout, res, format)
+						}
+					default:
+						err = runShell(path, ledger, format, os.Stdin)
+					}
+					if err != nil {
+						return cli.Exit(err, 1)
 					}
-					bean.PrintAccBalances(bals)
 					return nil
 				},
 			},
@@ -83,3 +326,71 @@ func Cmd() {
 		log.Fatal(err)
 	}
 }
+
+// printErrors writes each Error as file:line:column: message,
+// with warnings as file:line:column: warning: message
+func printErrors(w io.Writer, errs []bean.Error) {
+	for _, e := range errs {
+		if e.Severity == bean.SeverityWarning {
+			e.Message = "warning: " + e.Message
+		}
+		fmt.Fprintf(w, "%v\n", &e)
+	}
+}
+
+// reportFlags are the flags shared by the financial statements
+func reportFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.IntFlag{Name: "depth", Usage: "only show this many levels of accounts"},
+		&cli.StringFlag{Name: "convert", Aliases: []string{"c"}, Usage: "convert to this currency (default the operating currency)"},
+		&cli.StringFlag{Name: "value", Usage: "valuation: units, cost or market", Value: "units"},
+		&cli.StringFlag{Name: "format", Usage: "output format: text or json", Value: "text"},
+	}
+}
+
+// loadReport loads the file for a financial statement and returns
+// the ReportOptions from the flags, with the period ending now
+func loadReport(cCtx *cli.Context) (*bean.Ledger, bean.ReportOptions, error) {
+	opts := bean.ReportOptions{To: time.Now()}
+	path := cCtx.Args().First()
+	if len(path) == 0 {
+		return nil, opts, cli.Exit("Must provide a filepath as the first arg", 1)
+	}
+	if format := cCtx.String("format"); format != "text" && format != "json" {
+		return nil, opts, cli.Exit("format must be text or json", 1)
+	}
+	valuation, err := bean.NewValuation(cCtx.String("value"))
+	if err != nil {
+		return nil, opts, cli.Exit(err, 1)
+	}
+	ledger := bean.NewLedger(debug)
+	res := ledger.LoadFileAll(path)
+	printErrors(os.Stderr, res.Errors)
+	if res.HasErrors() {
+		return nil, opts, cli.Exit("", 1)
+	}
+	ccy := bean.Ccy(cCtx.String("convert"))
+	if ccy == "" && len(ledger.Options.OperatingCurrencies) > 0 {
+		ccy = ledger.Options.OperatingCurrencies[0]
+	}
+	if cCtx.IsSet("convert") && !cCtx.IsSet("value") {
+		valuation = bean.ValueMarket
+	}
+	if valuation != bean.ValueUnits {
+		if ccy == "" {
+			return nil, opts, cli.Exit("no currency to convert to: use --convert or an operating_currency option", 1)
+		}
+		opts.Ccy, opts.Valuation = ccy, valuation
+	}
+	if t := cCtx.Timestamp("from"); t != nil {
+		opts.From = *t
+	}
+	return ledger, opts, nil
+}
+
+// printJSON writes v to stdout as indented JSON
+func printJSON(v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -0,0 +1,222 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/carderne/gobean/bean"
+	"github.com/carderne/gobean/query"
+)
+
+const shellHelp = `Enter a query (eg SELECT account, sum(position) GROUP BY account) or a command:
+  .help            show this help
+  .tables          list the table and its columns
+  .functions       list the functions
+  .queries         list the query directives in the file
+  .run NAME        run a query directive
+  .format FORMAT   print results as table, csv or json
+  .reload          load the file again
+  .history         list previous commands
+  !N, !!           run command N, or the last command, again
+  .exit            leave (or Ctrl-D)
+`
+
+// historyFile is where the shell keeps its history, in the home directory
+const historyFile = ".gobean_history"
+
+// shell is an interactive prompt for running queries against a file
+type shell struct {
+	path    string
+	ledger  *bean.Ledger
+	format  query.Format
+	history []string
+	out     io.Writer
+	errOut  io.Writer
+}
+
+// runShell reads commands from in until it ends or .exit is entered
+func runShell(path string, ledger *bean.Ledger, format query.Format, in io.Reader) error {
+	s := &shell{path: path, ledger: ledger, format: format, out: os.Stdout, errOut: os.Stderr}
+	s.history = readHistory()
+	fmt.Fprintf(s.out, "Loaded %s. Type .help for help.\n", path)
+	scanner := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(s.out, "gobean> ")
+		if !scanner.Scan() {
+			fmt.Fprintln(s.out)
+			return scanner.Err()
+		}
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		line, err := s.expand(line)
+		if err != nil {
+			fmt.Fprintln(s.errOut, err)
+			continue
+		}
+		s.addHistory(line)
+		if line == ".exit" || line == ".quit" {
+			return nil
+		}
+		if err := s.run(line); err != nil {
+			fmt.Fprintln(s.errOut, err)
+		}
+	}
+}
+
+// expand replaces !N and !! with the commands from the history
+func (s *shell) expand(line string) (string, error) {
+	if line == "!!" {
+		if len(s.history) == 0 {
+			return "", fmt.Errorf("no history")
+		}
+		line = s.history[len(s.history)-1]
+	} else if strings.HasPrefix(line, "!") {
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 1 || n > len(s.history) {
+			return "", fmt.Errorf("no command %s in history", line[1:])
+		}
+		line = s.history[n-1]
+	} else {
+		return line, nil
+	}
+	fmt.Fprintln(s.out, line)
+	return line, nil
+}
+
+// run runs a single command or query
+func (s *shell) run(line string) error {
+	cmd, arg, _ := strings.Cut(line, " ")
+	arg = strings.TrimSpace(arg)
+	switch cmd {
+	case ".help":
+		fmt.Fprint(s.out, shellHelp)
+	case ".tables":
+		fmt.Fprintln(s.out, "postings")
+		for _, c := range query.Columns() {
+			fmt.Fprintf(s.out, "  %-14s %v\n", c.Name, c.Kind)
+		}
+	case ".functions":
+		scalar, aggregate := query.Functions()
+		fmt.Fprintf(s.out, "functions:  %s\naggregates: %s\n", strings.Join(scalar, ", "), strings.Join(aggregate, ", "))
+	case ".queries":
+		printQueries(s.out, s.ledger)
+	case ".run":
+		return runNamed(s.out, s.ledger, arg, s.format)
+	case ".format":
+		format, err := query.NewFormat(arg)
+		if err != nil {
+			return err
+		}
+		s.format = format
+	case ".reload":
+		ledger, err := loadForQuery(s.path)
+		if err != nil {
+			return err
+		}
+		s.ledger = ledger
+		fmt.Fprintf(s.out, "Reloaded %s\n", s.path)
+	case ".history":
+		for i, h := range s.history {
+			fmt.Fprintf(s.out, "%4d  %s\n", i+1, h)
+		}
+	default:
+		if strings.HasPrefix(cmd, ".") {
+			return fmt.Errorf("unknown command %s (try .help)", cmd)
+		}
+		res, err := query.Run(s.ledger, line)
+		if err != nil {
+			return err
+		}
+		return query.Print(s.out, res, s.format)
+	}
+	return nil
+}
+
+// addHistory adds line to the history and appends it to the history file
+func (s *shell) addHistory(line string) {
+	s.history = append(s.history, line)
+	path, err := historyPath()
+	if err != nil {
+		return
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	fmt.Fprintln(f, line)
+}
+
+// readHistory returns the commands from previous sessions, if any
+func readHistory() []string {
+	path, err := historyPath()
+	if err != nil {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var history []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			history = append(history, line)
+		}
+	}
+	return history
+}
+
+func historyPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, historyFile), nil
+}
+
+// printQueries lists the query directives in the Ledger
+func printQueries(w io.Writer, ledger *bean.Ledger) {
+	if len(ledger.Queries) == 0 {
+		fmt.Fprintln(w, "No query directives")
+		return
+	}
+	width := 0
+	for _, q := range ledger.Queries {
+		width = max(width, len(q.Name))
+	}
+	for _, q := range ledger.Queries {
+		fmt.Fprintf(w, "%-*s  %s\n", width, q.Name, q.SQL)
+	}
+}
+
+// runNamed runs the query directive called name
+func runNamed(w io.Writer, ledger *bean.Ledger, name string, format query.Format) error {
+	q, ok := query.Named(ledger, name)
+	if !ok {
+		return fmt.Errorf("no query called %q (see .queries)", name)
+	}
+	res, err := query.Run(ledger, q.SQL)
+	if err != nil {
+		return fmt.Errorf("query %s: %w", name, err)
+	}
+	return query.Print(w, res, format)
+}
+
+// loadForQuery loads a file, printing any errors. Queries can still be
+// run against a file with errors, so only failing to read it is an error.
+func loadForQuery(path string) (*bean.Ledger, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	ledger := bean.NewLedger(debug)
+	res := ledger.LoadFileAll(path)
+	printErrors(os.Stderr, res.Errors)
+	return res.Ledger, nil
+}
@@ -0,0 +1,292 @@
+// Package printer writes gobean Entries back out as beancount
+package printer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/carderne/gobean/bean"
+)
+
+// Printer writes Entries as beancount, with the decimal points
+// of amounts aligned in a column
+type Printer struct {
+	Column int // column (from 0) of the decimal point, 0 to fit the widest line
+	Indent int // spaces before postings and metadata
+}
+
+// New creates a Printer with the default settings
+func New() *Printer {
+	return &Printer{Indent: 2}
+}
+
+// line is one line of output, split around the number
+// to be aligned (if any)
+type line struct {
+	prefix string
+	number string
+	suffix string
+}
+
+// Fprint writes e to w
+func (p *Printer) Fprint(w io.Writer, e bean.Entry) error {
+	lines, err := p.entryLines(e)
+	if err != nil {
+		return err
+	}
+	return p.write(w, lines)
+}
+
+// Format rewrites a beancount file from src to w, printing every Entry
+// with the amounts of the whole file aligned. Everything between Entries
+// (comments, blank lines, options, includes etc) is kept as it is.
+// Comments inside Entries are kept at the end of their line,
+// or on their own line after it.
+// Files that fail to parse are not written.
+func (p *Printer) Format(w io.Writer, src []byte) error {
+	entries, err := bean.ParseEntries(io.NopCloser(bytes.NewReader(src)))
+	if err != nil {
+		return fmt.Errorf("in Format: %w", err)
+	}
+	starts := make(map[int]bean.SourceEntry, len(entries))
+	for _, e := range entries {
+		starts[e.Entry.EntryPos().Line] = e
+	}
+
+	text := strings.TrimSuffix(string(src), "\n")
+	srcLines := strings.Split(text, "\n")
+	var lines []line
+	for i := 0; i < len(srcLines); i++ {
+		e, ok := starts[i+1]
+		if !ok {
+			lines = append(lines, line{prefix: strings.TrimRight(srcLines[i], " \t\r")})
+			continue
+		}
+		entryLines, err := p.entryLines(e.Entry)
+		if err != nil {
+			return fmt.Errorf("in Format: %w", err)
+		}
+		lines = append(lines, p.addComments(entryLines, e.Comments)...)
+		i = e.LastLine - 1
+	}
+	return p.write(w, lines)
+}
+
+// addComments adds the comments of an Entry to its lines,
+// with the comments on their own lines indented like postings
+func (p *Printer) addComments(lines []line, comments []bean.Comment) []line {
+	if len(comments) == 0 {
+		return lines
+	}
+	res := make([]line, 0, len(lines)+len(comments))
+	c := 0
+	for i, l := range lines {
+		var own []line
+		for ; c < len(comments) && comments[c].Line <= i; c++ {
+			if comments[c].Inline {
+				l.suffix += " " + comments[c].Text
+			} else {
+				own = append(own, line{prefix: strings.Repeat(" ", p.Indent) + comments[c].Text})
+			}
+		}
+		res = append(res, l)
+		res = append(res, own...)
+	}
+	return res
+}
+
+// write writes the lines with the numbers aligned
+func (p *Printer) write(w io.Writer, lines []line) error {
+	column := p.Column
+	if column <= 0 {
+		for _, l := range lines {
+			if l.number != "" {
+				column = max(column, len(l.prefix)+2+intWidth(l.number))
+			}
+		}
+	}
+	var b strings.Builder
+	for _, l := range lines {
+		b.WriteString(l.prefix)
+		if l.number != "" {
+			pad := max(2, column-len(l.prefix)-intWidth(l.number))
+			b.WriteString(strings.Repeat(" ", pad))
+			b.WriteString(l.number)
+		}
+		b.WriteString(l.suffix)
+		b.WriteByte('\n')
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// intWidth returns the width of a number before its decimal point
+func intWidth(number string) int {
+	if i := strings.IndexByte(number, '.'); i >= 0 {
+		return i
+	}
+	return len(number)
+}
+
+// entryLines returns the lines for an Entry, followed by its metadata
+func (p *Printer) entryLines(e bean.Entry) ([]line, error) {
+	date := e.EntryDate().Format(time.DateOnly)
+	var lines []line
+	switch e := e.(type) {
+	case bean.AccountEvent:
+		if !e.Open {
+			lines = append(lines, line{prefix: fmt.Sprintf("%s close %s", date, e.Account.Name)})
+			break
+		}
+		str := fmt.Sprintf("%s open %s", date, e.Account.Name)
+		if len(e.Ccys) > 0 {
+			ccys := make([]string, len(e.Ccys))
+			for i, ccy := range e.Ccys {
+				ccys[i] = string(ccy)
+			}
+			str += " " + strings.Join(ccys, ",")
+		}
+		if e.Booking != "" {
+			str += " " + quote(string(e.Booking))
+		}
+		lines = append(lines, line{prefix: str})
+	case bean.Balance:
+		suffix := " " + string(e.Amount.Ccy)
+		if e.Tolerance != nil {
+			suffix = " ~ " + e.Tolerance.Text('f') + suffix
+		}
+		lines = append(lines, line{
+			prefix: fmt.Sprintf("%s balance %s", date, e.Account.Name),
+			number: e.Amount.Number.Text('f'),
+			suffix: suffix,
+		})
+	case bean.Transaction:
+		lines = append(lines, line{prefix: transactionHeader(date, e)})
+		lines = append(lines, p.metaLines(e.Meta, 1)...)
+		for _, posting := range e.Postings {
+			lines = append(lines, p.postingLine(posting))
+			lines = append(lines, p.metaLines(posting.Meta, 2)...)
+		}
+		return lines, nil
+	case bean.Price:
+		lines = append(lines, line{
+			prefix: fmt.Sprintf("%s price %s", date, e.Ccy),
+			number: e.Amount.Number.Text('f'),
+			suffix: " " + string(e.Amount.Ccy),
+		})
+	case bean.Pad:
+		lines = append(lines, line{prefix: fmt.Sprintf("%s pad %s %s", date, e.PadTo.Name, e.PadFrom.Name)})
+	case bean.Note:
+		lines = append(lines, line{prefix: fmt.Sprintf("%s note %s %s", date, e.Account.Name, quote(e.Comment))})
+	case bean.Document:
+		str := fmt.Sprintf("%s document %s %s", date, e.Account.Name, quote(e.Path))
+		lines = append(lines, line{prefix: str + tagsAndLinks(e.Tags, e.Links)})
+	case bean.Event:
+		lines = append(lines, line{prefix: fmt.Sprintf("%s event %s %s", date, quote(e.Name), quote(e.Value))})
+	case bean.Commodity:
+		lines = append(lines, line{prefix: fmt.Sprintf("%s commodity %s", date, e.Ccy)})
+	case bean.Query:
+		lines = append(lines, line{prefix: fmt.Sprintf("%s query %s %s", date, quote(e.Name), quote(e.SQL))})
+	case bean.Custom:
+		str := fmt.Sprintf("%s custom %s", date, quote(e.Type))
+		for _, v := range e.Values {
+			str += " " + metaValue(v)
+		}
+		lines = append(lines, line{prefix: str})
+	default:
+		return nil, fmt.Errorf("unknown entry type %T", e)
+	}
+	return append(lines, p.metaLines(e.EntryMeta(), 1)...), nil
+}
+
+// transactionHeader returns the first line of a Transaction
+func transactionHeader(date string, tx bean.Transaction) string {
+	str := date + " " + tx.Type
+	if tx.Payee != "" {
+		str += " " + quote(tx.Payee)
+	}
+	if tx.Payee != "" || tx.Narration != "" {
+		str += " " + quote(tx.Narration)
+	}
+	return str + tagsAndLinks(tx.Tags, tx.Links)
+}
+
+// postingLine returns the line for a Posting, aligned on its units
+func (p *Printer) postingLine(posting bean.Posting) line {
+	l := line{prefix: strings.Repeat(" ", p.Indent) + string(posting.Account.Name)}
+	if posting.Amount != nil {
+		l.number = posting.Amount.Number.Text('f')
+		l.suffix = " " + string(posting.Amount.Ccy)
+	}
+	if posting.Cost != nil {
+		l.suffix += " " + cost(*posting.Cost)
+	}
+	if posting.Price != nil {
+		at := "@"
+		if posting.PriceTotal {
+			at = "@@"
+		}
+		l.suffix += fmt.Sprintf(" %s %s %s", at, posting.Price.Number.Text('f'), posting.Price.Ccy)
+	}
+	return l
+}
+
+// cost returns a Cost as beancount
+func cost(c bean.Cost) string {
+	var parts []string
+	if c.Amount != nil {
+		parts = append(parts, c.Amount.Number.Text('f')+" "+string(c.Amount.Ccy))
+	}
+	if !c.Date.IsZero() {
+		parts = append(parts, c.Date.Format(time.DateOnly))
+	}
+	if c.Label != "" {
+		parts = append(parts, quote(c.Label))
+	}
+	str := strings.Join(parts, ", ")
+	if c.Total {
+		return "{{" + str + "}}"
+	}
+	return "{" + str + "}"
+}
+
+// metaLines returns a line for each key of meta, in source order,
+// indented by level
+func (p *Printer) metaLines(meta bean.Meta, level int) []line {
+	keys := meta.Keys()
+	lines := make([]line, len(keys))
+	indent := strings.Repeat(" ", level*p.Indent)
+	for i, key := range keys {
+		lines[i] = line{prefix: fmt.Sprintf("%s%s: %s", indent, key, metaValue(meta[key]))}
+	}
+	return lines
+}
+
+// metaValue returns a MetaValue as beancount
+func metaValue(v bean.MetaValue) string {
+	if v.Kind == bean.MetaString {
+		return quote(v.Text)
+	}
+	return v.String()
+}
+
+// tagsAndLinks returns the sorted #tags and ^links with a leading space,
+// or an empty string if there are none
+func tagsAndLinks(tags bean.Set, links bean.Set) string {
+	var str string
+	for _, tag := range tags.Sorted() {
+		str += " #" + tag
+	}
+	for _, link := range links.Sorted() {
+		str += " ^" + link
+	}
+	return str
+}
+
+// quote returns s as a beancount string
+func quote(s string) string {
+	return bean.Quote(s)
+}
@@ -0,0 +1,142 @@
+package printer
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/carderne/gobean/bean"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFormat(t *testing.T) {
+	src := `; header comment
+option "title" "Test"
+
+2023-01-01 open Assets:Bank   GBP,USD   "FIFO"
+2023-01-01 open Expenses:Food
+  description: "Food, drink"
+
+* Section
+2023-01-02 * "Shop"   "Groceries \"weekly\""  #food ^r1
+  receipt: TRUE
+  category: "weekly"
+  Assets:Bank   -10.5 GBP
+  Expenses:Food  1 FOO {{10.5 GBP, "lot"}} @ 10.50 GBP
+    note: "posting meta"
+2023-01-03 txn "Just narration" ; header
+  ; on its own line
+  Assets:Bank  1 GBP   ; inline
+  Expenses:Food ; keep me
+2023-01-04 balance Assets:Bank  -9.5 ~ 0.01 GBP   
+2023-01-04 price FOO  1200.25 GBP
+2023-01-05 pad Assets:Bank Expenses:Food
+2023-01-05 note Assets:Bank "Called"
+2023-01-05 document Assets:Bank "/tmp/a.pdf" #tax
+2023-01-05 event "location" "London"
+2023-01-05 commodity FOO
+2023-01-05 query "cash" "SELECT account"
+2023-01-05 custom "budget" Expenses:Food 100 GBP TRUE
+2023-01-06 close Expenses:Food
+`
+	want := `; header comment
+option "title" "Test"
+
+2023-01-01 open Assets:Bank GBP,USD "FIFO"
+2023-01-01 open Expenses:Food
+  description: "Food, drink"
+
+* Section
+2023-01-02 * "Shop" "Groceries \"weekly\"" #food ^r1
+  receipt: TRUE
+  category: "weekly"
+  Assets:Bank                  -10.5 GBP
+  Expenses:Food                  1 FOO {{10.5 GBP, "lot"}} @ 10.50 GBP
+    note: "posting meta"
+2023-01-03 txn "Just narration" ; header
+  ; on its own line
+  Assets:Bank                    1 GBP ; inline
+  Expenses:Food ; keep me
+2023-01-04 balance Assets:Bank  -9.5 ~ 0.01 GBP
+2023-01-04 price FOO          1200.25 GBP
+2023-01-05 pad Assets:Bank Expenses:Food
+2023-01-05 note Assets:Bank "Called"
+2023-01-05 document Assets:Bank "/tmp/a.pdf" #tax
+2023-01-05 event "location" "London"
+2023-01-05 commodity FOO
+2023-01-05 query "cash" "SELECT account"
+2023-01-05 custom "budget" Expenses:Food 100 GBP TRUE
+2023-01-06 close Expenses:Food
+`
+	var b strings.Builder
+	if err := New().Format(&b, []byte(src)); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, b.String()); diff != "" {
+		t.Error(diff)
+	}
+
+	// formatting should be stable, and the result should load
+	var again strings.Builder
+	if err := New().Format(&again, []byte(b.String())); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(b.String(), again.String()); diff != "" {
+		t.Error(diff)
+	}
+	if err := New().Format(io.Discard, []byte("2023-01-01 open")); err == nil {
+		t.Error("should not format a file that doesn't parse")
+	}
+}
+
+func TestFormat_strings(t *testing.T) {
+	// strings should round trip through the lexer unchanged
+	texts := []string{"a\u00a0b", "caf\u00e9 \U0001F600", "tab\there", "ctrl\x01", "back\\slash", "say \"hi\"", "two\nlines"}
+	var src strings.Builder
+	for _, text := range texts {
+		src.WriteString("2023-01-01 note Assets:Bank " + bean.Quote(text) + "\n")
+	}
+	var b strings.Builder
+	if err := New().Format(&b, []byte(src.String())); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(src.String(), b.String()); diff != "" {
+		t.Error(diff)
+	}
+	entries, err := bean.ParseEntries(io.NopCloser(strings.NewReader(b.String())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range entries {
+		got = append(got, e.Entry.(bean.Note).Comment)
+	}
+	if diff := cmp.Diff(texts, got); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestFprint_column(t *testing.T) {
+	amt := bean.MustNewAmount("-1.25", "GBP")
+	tx := bean.Transaction{
+		Type:      "*",
+		Narration: "Coffee",
+		Postings: []bean.Posting{
+			{Account: bean.Account{Name: "Assets:Bank"}, Amount: &amt},
+			{Account: bean.Account{Name: "Expenses:Coffee"}},
+		},
+	}
+	p := New()
+	p.Column = 30
+	var b strings.Builder
+	if err := p.Fprint(&b, tx); err != nil {
+		t.Fatal(err)
+	}
+	want := `0001-01-01 * "Coffee"
+  Assets:Bank               -1.25 GBP
+  Expenses:Coffee
+`
+	if diff := cmp.Diff(want, b.String()); diff != "" {
+		t.Error(diff)
+	}
+}
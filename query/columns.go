@@ -0,0 +1,360 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/carderne/gobean/bean"
+	"github.com/cockroachdb/apd/v3"
+)
+
+// row is a Posting with its Transaction.
+// posting is nil when evaluating the FROM clause.
+type row struct {
+	tx      *bean.Transaction
+	posting *bean.Posting
+}
+
+// column is a value available on every row
+type column struct {
+	kind    Kind
+	posting bool // needs a Posting, so not allowed in FROM
+	get     func(r row) Value
+}
+
+// columns are the names that can be used in expressions
+var columns = map[string]column{
+	"date":      {KindDate, false, func(r row) Value { return dateValue(r.tx.Date) }},
+	"year":      {KindNumber, false, func(r row) Value { return intValue(r.tx.Date.Year()) }},
+	"month":     {KindNumber, false, func(r row) Value { return intValue(int(r.tx.Date.Month())) }},
+	"day":       {KindNumber, false, func(r row) Value { return intValue(r.tx.Date.Day()) }},
+	"flag":      {KindString, false, func(r row) Value { return stringValue(flag(r.tx.Type)) }},
+	"payee":     {KindString, false, func(r row) Value { return stringValue(r.tx.Payee) }},
+	"narration": {KindString, false, func(r row) Value { return stringValue(r.tx.Narration) }},
+	"tags":      {KindSet, false, func(r row) Value { return setValue(r.tx.Tags) }},
+	"links":     {KindSet, false, func(r row) Value { return setValue(r.tx.Links) }},
+	"account":   {KindString, true, func(r row) Value { return stringValue(string(r.posting.Account.Name)) }},
+	"position":  {KindPosition, true, func(r row) Value { return positionValue(position(r)) }},
+	"units":     {KindAmount, true, func(r row) Value { return amountValue(position(r).Units) }},
+	"number":    {KindNumber, true, func(r row) Value { return numberValue(position(r).Units.Number) }},
+	"currency":  {KindString, true, func(r row) Value { return stringValue(string(position(r).Units.Ccy)) }},
+	"cost":      {KindAmount, true, func(r row) Value { return costValue(position(r)) }},
+	"cost_number": {KindNumber, true, func(r row) Value {
+		if lot := position(r).Lot; lot != nil {
+			return numberValue(lot.Cost.Number)
+		}
+		return Null
+	}},
+	"cost_currency": {KindString, true, func(r row) Value {
+		if lot := position(r).Lot; lot != nil {
+			return stringValue(string(lot.Cost.Ccy))
+		}
+		return Null
+	}},
+	"price":  {KindAmount, true, priceValue},
+	"weight": {KindAmount, true, func(r row) Value { return amountValue(r.posting.Weight()) }},
+}
+
+// flag returns the flag of a Transaction type, where txn is *
+func flag(txType string) string {
+	if txType == "txn" {
+		return "*"
+	}
+	return txType
+}
+
+// position returns the Posting of r as a Position,
+// with a Lot if it is held at cost
+func position(r row) bean.Position {
+	p := r.posting
+	pos := bean.Position{}
+	if p.Amount != nil {
+		pos.Units = *p.Amount
+	}
+	if p.Cost == nil || p.Cost.Amount == nil || p.Amount == nil {
+		return pos
+	}
+	perUnit := *p.Cost.Amount
+	if p.Cost.Total && !p.Amount.Number.IsZero() {
+		// Quo only fails on division by zero
+		perUnit, _ = perUnit.Quo(p.Amount.Abs().Number)
+	}
+	date := p.Cost.Date
+	if date.IsZero() {
+		date = r.tx.Date
+	}
+	pos.Lot = &bean.Lot{Cost: perUnit, Date: date, Label: p.Cost.Label}
+	return pos
+}
+
+// costValue returns the total cost of a Position, or NULL if it has none
+func costValue(p bean.Position) Value {
+	if p.Lot == nil {
+		return Null
+	}
+	return amountValue(p.Lot.Cost.Mul(p.Units.Number))
+}
+
+// priceValue returns the per-unit price of the Posting, or NULL if it has none
+func priceValue(r row) Value {
+	p := r.posting
+	if p.Price == nil {
+		return Null
+	}
+	if !p.PriceTotal || p.Amount == nil || p.Amount.Number.IsZero() {
+		return amountValue(*p.Price)
+	}
+	perUnit, _ := p.Price.Quo(p.Amount.Abs().Number)
+	return amountValue(perUnit)
+}
+
+// function is a scalar function, applied to each row
+type function struct {
+	args int
+	kind func(args []Kind) Kind
+	call func(args []Value) (Value, error)
+}
+
+// returns makes a kind function for a function that always returns k
+func returns(k Kind) func([]Kind) Kind {
+	return func([]Kind) Kind { return k }
+}
+
+// functions are the scalar functions, which return NULL for NULL arguments
+var functions = map[string]function{
+	"year":   {1, returns(KindNumber), dateFunc(func(v Value) Value { return intValue(v.Date.Year()) })},
+	"month":  {1, returns(KindNumber), dateFunc(func(v Value) Value { return intValue(int(v.Date.Month())) })},
+	"day":    {1, returns(KindNumber), dateFunc(func(v Value) Value { return intValue(v.Date.Day()) })},
+	"parent": {1, returns(KindString), stringFunc(func(s string) Value { return accountPart(s, 0, -1) })},
+	"leaf":   {1, returns(KindString), stringFunc(func(s string) Value { return accountPart(s, -1, 0) })},
+	"units": {1, sameKind, func(args []Value) (Value, error) {
+		switch v := args[0]; v.Kind {
+		case KindPosition:
+			return amountValue(v.Position.Units), nil
+		case KindInventory:
+			inv := &bean.Inventory{}
+			for _, p := range v.Inventory.Positions {
+				inv.Add(p.Units, nil)
+			}
+			return inventoryValue(inv), nil
+		}
+		return badArgs("units", args)
+	}},
+	"cost": {1, sameKind, func(args []Value) (Value, error) {
+		switch v := args[0]; v.Kind {
+		case KindPosition:
+			if v.Position.Lot == nil {
+				return amountValue(v.Position.Units), nil
+			}
+			return costValue(v.Position), nil
+		case KindInventory:
+			inv := &bean.Inventory{}
+			for _, p := range v.Inventory.Positions {
+				amt := p.Units
+				if p.Lot != nil {
+					amt = p.Lot.Cost.Mul(p.Units.Number)
+				}
+				inv.Add(amt, nil)
+			}
+			return inventoryValue(inv), nil
+		}
+		return badArgs("cost", args)
+	}},
+	"number": {1, returns(KindNumber), func(args []Value) (Value, error) {
+		switch v := args[0]; v.Kind {
+		case KindAmount:
+			return numberValue(v.Amount.Number), nil
+		case KindPosition:
+			return numberValue(v.Position.Units.Number), nil
+		}
+		return badArgs("number", args)
+	}},
+	"currency": {1, returns(KindString), func(args []Value) (Value, error) {
+		switch v := args[0]; v.Kind {
+		case KindAmount:
+			return stringValue(string(v.Amount.Ccy)), nil
+		case KindPosition:
+			return stringValue(string(v.Position.Units.Ccy)), nil
+		}
+		return badArgs("currency", args)
+	}},
+	"root": {2, returns(KindString), func(args []Value) (Value, error) {
+		if args[0].Kind != KindString || args[1].Kind != KindNumber {
+			return badArgs("root", args)
+		}
+		n, err := args[1].Number.Int64()
+		if err != nil || n < 1 {
+			return Null, fmt.Errorf("root needs a positive integer, found %v", args[1])
+		}
+		parts := strings.Split(args[0].Text, ":")
+		return stringValue(strings.Join(parts[:min(int(n), len(parts))], ":")), nil
+	}},
+	"abs": {1, sameKind, func(args []Value) (Value, error) {
+		switch v := args[0]; v.Kind {
+		case KindNumber:
+			var d apd.Decimal
+			d.Abs(&v.Number)
+			return numberValue(d), nil
+		case KindAmount:
+			return amountValue(v.Amount.Abs()), nil
+		}
+		return badArgs("abs", args)
+	}},
+	"length": {1, returns(KindNumber), func(args []Value) (Value, error) {
+		switch v := args[0]; v.Kind {
+		case KindString:
+			return intValue(len([]rune(v.Text))), nil
+		case KindSet:
+			return intValue(len(v.Set)), nil
+		}
+		return badArgs("length", args)
+	}},
+}
+
+// sameKind is the kind function for functions that return the kind they are given
+func sameKind(args []Kind) Kind {
+	if args[0] == KindPosition {
+		return KindAmount
+	}
+	return args[0]
+}
+
+func badArgs(name string, args []Value) (Value, error) {
+	kinds := make([]string, len(args))
+	for i, a := range args {
+		kinds[i] = a.Kind.String()
+	}
+	return Null, fmt.Errorf("%s can't be applied to (%s)", name, strings.Join(kinds, ", "))
+}
+
+// dateFunc makes a function of a single date
+func dateFunc(fn func(Value) Value) func([]Value) (Value, error) {
+	return func(args []Value) (Value, error) {
+		if args[0].Kind != KindDate {
+			return badArgs("date function", args)
+		}
+		return fn(args[0]), nil
+	}
+}
+
+// stringFunc makes a function of a single string
+func stringFunc(fn func(string) Value) func([]Value) (Value, error) {
+	return func(args []Value) (Value, error) {
+		if args[0].Kind != KindString {
+			return badArgs("string function", args)
+		}
+		return fn(args[0].Text), nil
+	}
+}
+
+// accountPart returns the components of an account name from start to end,
+// counting negative indexes from the end (so 0, -1 is the parent)
+func accountPart(name string, start int, end int) Value {
+	parts := strings.Split(name, ":")
+	if start < 0 {
+		start += len(parts)
+	}
+	end += len(parts)
+	if end <= start {
+		return Null
+	}
+	return stringValue(strings.Join(parts[start:end], ":"))
+}
+
+// aggregate is a function that combines the values of a group of rows
+type aggregate struct {
+	kind func(arg Kind) Kind
+	call func(values []Value) (Value, error)
+}
+
+// aggregates are the aggregate functions, which ignore NULL values
+var aggregates = map[string]aggregate{
+	"sum": {
+		kind: func(arg Kind) Kind {
+			if arg == KindNumber {
+				return KindNumber
+			}
+			return KindInventory
+		},
+		call: sum,
+	},
+	"count": {
+		kind: func(Kind) Kind { return KindNumber },
+		call: func(values []Value) (Value, error) { return intValue(len(values)), nil },
+	},
+	"first": {
+		kind: func(arg Kind) Kind { return arg },
+		call: func(values []Value) (Value, error) {
+			if len(values) == 0 {
+				return Null, nil
+			}
+			return values[0], nil
+		},
+	},
+	"last": {
+		kind: func(arg Kind) Kind { return arg },
+		call: func(values []Value) (Value, error) {
+			if len(values) == 0 {
+				return Null, nil
+			}
+			return values[len(values)-1], nil
+		},
+	},
+	"min": {
+		kind: func(arg Kind) Kind { return arg },
+		call: func(values []Value) (Value, error) { return extreme(values, -1), nil },
+	},
+	"max": {
+		kind: func(arg Kind) Kind { return arg },
+		call: func(values []Value) (Value, error) { return extreme(values, 1), nil },
+	},
+}
+
+// sum adds up numbers into a number, and everything else into an Inventory
+func sum(values []Value) (Value, error) {
+	if len(values) == 0 {
+		return Null, nil
+	}
+	if values[0].Kind == KindNumber {
+		var total apd.Decimal
+		for _, v := range values {
+			if v.Kind != KindNumber {
+				return badArgs("sum", []Value{v})
+			}
+			if _, err := apdCtx.Add(&total, &total, &v.Number); err != nil {
+				return Null, fmt.Errorf("in sum: %w", err)
+			}
+		}
+		return numberValue(total), nil
+	}
+	inv := &bean.Inventory{}
+	for _, v := range values {
+		switch v.Kind {
+		case KindAmount:
+			inv.Add(v.Amount, nil)
+		case KindPosition:
+			inv.Add(v.Position.Units, v.Position.Lot)
+		case KindInventory:
+			for _, p := range v.Inventory.Positions {
+				inv.Add(p.Units, p.Lot)
+			}
+		default:
+			return badArgs("sum", []Value{v})
+		}
+	}
+	return inventoryValue(inv), nil
+}
+
+// extreme returns the smallest (sign -1) or largest (sign 1) value
+func extreme(values []Value, sign int) Value {
+	if len(values) == 0 {
+		return Null
+	}
+	res := values[0]
+	for _, v := range values[1:] {
+		if compare(v, res)*sign > 0 {
+			res = v
+		}
+	}
+	return res
+}
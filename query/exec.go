@@ -0,0 +1,687 @@
+// Package query runs beancount query language (BQL) queries against a Ledger
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/carderne/gobean/bean"
+	"github.com/cockroachdb/apd/v3"
+)
+
+// apd Decimal context for arithmetic
+var apdCtx = apd.BaseContext.WithPrecision(24)
+
+// Column is the name and type of a column in a Result
+type Column struct {
+	Name string `json:"name"`
+	Kind Kind   `json:"kind"`
+}
+
+// Row is one row of a Result, with a Value for each Column
+type Row []Value
+
+// Result is the output of a query
+type Result struct {
+	Columns []Column `json:"columns"`
+	Rows    []Row    `json:"rows"`
+}
+
+// Run parses and executes the query q against the Ledger
+func Run(l *bean.Ledger, q string) (*Result, error) {
+	sel, err := Parse(q)
+	if err != nil {
+		return nil, err
+	}
+	return Execute(l, sel)
+}
+
+// Execute runs a parsed query against the Ledger.
+// Each row is a Posting of the Transactions selected by FROM.
+func Execute(l *bean.Ledger, sel *Select) (*Result, error) {
+	res := &Result{Columns: make([]Column, len(sel.Targets))}
+	grouped := len(sel.GroupBy) > 0
+	for i, t := range sel.Targets {
+		kind, err := typeOf(t.Expr)
+		if err != nil {
+			return nil, fmt.Errorf("in Execute: %w", err)
+		}
+		res.Columns[i] = Column{Name: t.Name, Kind: kind}
+		grouped = grouped || hasAggregate(t.Expr)
+	}
+	groupBy := make([]Expr, len(sel.GroupBy))
+	for i, expr := range sel.GroupBy {
+		groupBy[i] = sel.resolve(expr)
+	}
+	if grouped && len(groupBy) == 0 {
+		// group by the targets that aren't aggregates
+		for _, t := range sel.Targets {
+			if !hasAggregate(t.Expr) {
+				groupBy = append(groupBy, t.Expr)
+			}
+		}
+	}
+	orderBy := make([]OrderTerm, len(sel.OrderBy))
+	for i, term := range sel.OrderBy {
+		orderBy[i] = OrderTerm{Expr: sel.resolve(term.Expr), Desc: term.Desc}
+	}
+	if err := sel.check(groupBy, orderBy, grouped); err != nil {
+		return nil, fmt.Errorf("in Execute: %w", err)
+	}
+
+	e := &evaluator{regexps: map[string]*regexp.Regexp{}}
+	txs, err := e.transactions(l, sel.From)
+	if err != nil {
+		return nil, fmt.Errorf("in Execute: %w", err)
+	}
+	var rows []row
+	for i := range txs {
+		tx := &txs[i]
+		for j := range tx.Postings {
+			r := row{tx: tx, posting: &tx.Postings[j]}
+			if sel.Where != nil {
+				v, err := e.eval(sel.Where, []row{r})
+				if err != nil {
+					return nil, fmt.Errorf("in Execute: %w", err)
+				}
+				if !v.truthy() {
+					continue
+				}
+			}
+			rows = append(rows, r)
+		}
+	}
+
+	groups, err := e.group(rows, groupBy, grouped)
+	if err != nil {
+		return nil, fmt.Errorf("in Execute: %w", err)
+	}
+	type output struct {
+		row  Row
+		keys []Value
+	}
+	outputs := make([]output, 0, len(groups))
+	seen := map[string]bool{}
+	for _, g := range groups {
+		out := output{row: make(Row, len(sel.Targets)), keys: make([]Value, len(orderBy))}
+		for i, t := range sel.Targets {
+			if out.row[i], err = e.eval(t.Expr, g); err != nil {
+				return nil, fmt.Errorf("in Execute: %w", err)
+			}
+		}
+		if sel.Distinct {
+			key := rowKey(out.row)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+		}
+		for i, term := range orderBy {
+			if out.keys[i], err = e.eval(term.Expr, g); err != nil {
+				return nil, fmt.Errorf("in Execute: %w", err)
+			}
+		}
+		outputs = append(outputs, out)
+	}
+	sort.SliceStable(outputs, func(i, j int) bool {
+		for k, term := range orderBy {
+			c := compare(outputs[i].keys[k], outputs[j].keys[k])
+			if c == 0 {
+				continue
+			}
+			if term.Desc {
+				return c > 0
+			}
+			return c < 0
+		}
+		return false
+	})
+	if sel.Limit > 0 && len(outputs) > sel.Limit {
+		outputs = outputs[:sel.Limit]
+	}
+	res.Rows = make([]Row, len(outputs))
+	for i, out := range outputs {
+		res.Rows[i] = out.row
+	}
+	return res, nil
+}
+
+// resolve replaces a column number (from 1) or a target name
+// in GROUP BY or ORDER BY with the target expression
+func (s *Select) resolve(expr Expr) Expr {
+	switch e := expr.(type) {
+	case Literal:
+		if e.Value.Kind != KindNumber {
+			break
+		}
+		if n, err := e.Value.Number.Int64(); err == nil && n >= 1 && int(n) <= len(s.Targets) {
+			return s.Targets[n-1].Expr
+		}
+	case ColumnRef:
+		if _, ok := columns[e.Name]; ok {
+			break
+		}
+		for _, t := range s.Targets {
+			if strings.EqualFold(t.Name, e.Name) {
+				return t.Expr
+			}
+		}
+	}
+	return expr
+}
+
+// check returns an error for expressions that can't be evaluated,
+// aggregates outside of the targets and ORDER BY, and targets that
+// are neither aggregated nor grouped
+func (s *Select) check(groupBy []Expr, orderBy []OrderTerm, grouped bool) error {
+	exprs := append([]Expr{}, groupBy...)
+	for _, term := range orderBy {
+		exprs = append(exprs, term.Expr)
+	}
+	if s.Where != nil {
+		exprs = append(exprs, s.Where)
+	}
+	if s.From.Expr != nil {
+		exprs = append(exprs, s.From.Expr)
+		if usesPosting(s.From.Expr) {
+			return fmt.Errorf("FROM can only use transaction columns: %v", s.From.Expr)
+		}
+	}
+	for _, expr := range exprs {
+		if _, err := typeOf(expr); err != nil {
+			return err
+		}
+	}
+	for _, expr := range append(groupBy, s.Where, s.From.Expr) {
+		if expr != nil && hasAggregate(expr) {
+			return fmt.Errorf("aggregates are not allowed in FROM, WHERE or GROUP BY: %v", expr)
+		}
+	}
+	if !grouped || len(groupBy) == 0 {
+		return nil
+	}
+	keys := map[string]bool{}
+	for _, expr := range groupBy {
+		keys[expr.String()] = true
+	}
+	for _, t := range s.Targets {
+		if !hasAggregate(t.Expr) && !keys[t.Expr.String()] {
+			return fmt.Errorf("%v must be aggregated or in GROUP BY", t.Expr)
+		}
+	}
+	return nil
+}
+
+// group splits the rows into groups with the same GROUP BY values,
+// in the order they are first seen. If there is nothing to group by,
+// all the rows are one group. Without aggregates, each row is its own group.
+func (e *evaluator) group(rows []row, groupBy []Expr, grouped bool) ([][]row, error) {
+	if !grouped {
+		groups := make([][]row, len(rows))
+		for i, r := range rows {
+			groups[i] = []row{r}
+		}
+		return groups, nil
+	}
+	if len(groupBy) == 0 {
+		return [][]row{rows}, nil
+	}
+	var groups [][]row
+	index := map[string]int{}
+	for _, r := range rows {
+		key := make(Row, len(groupBy))
+		for i, expr := range groupBy {
+			v, err := e.eval(expr, []row{r})
+			if err != nil {
+				return nil, err
+			}
+			key[i] = v
+		}
+		k := rowKey(key)
+		i, ok := index[k]
+		if !ok {
+			i = len(groups)
+			index[k] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], r)
+	}
+	return groups, nil
+}
+
+// rowKey returns a string that is equal for equal Rows
+func rowKey(r Row) string {
+	keys := make([]string, len(r))
+	for i, v := range r {
+		keys[i] = v.key()
+	}
+	return strings.Join(keys, "\x00")
+}
+
+// transactions returns the Transactions selected by FROM, sorted by date,
+// with OPEN, CLOSE and CLEAR applied
+func (e *evaluator) transactions(l *bean.Ledger, from From) ([]bean.Transaction, error) {
+	txs := make([]bean.Transaction, 0, len(l.Transactions))
+	for i := range l.Transactions {
+		tx := l.Transactions[i]
+		if from.Expr != nil {
+			v, err := e.eval(from.Expr, []row{{tx: &tx}})
+			if err != nil {
+				return nil, err
+			}
+			if !v.truthy() {
+				continue
+			}
+		}
+		txs = append(txs, tx)
+	}
+	sort.SliceStable(txs, func(i, j int) bool { return txs[i].Date.Before(txs[j].Date) })
+
+	opts := l.Options
+	equity := func(name string) bean.AccountName {
+		return bean.AccountName(opts.NameEquity + ":" + name)
+	}
+	if !from.Open.IsZero() {
+		i := sort.Search(len(txs), func(i int) bool { return !txs[i].Date.Before(from.Open) })
+		// Income and Expenses before the open date are earnings of previous periods
+		invs := inventories(txs[:i], func(acc bean.AccountName) bean.AccountName {
+			if isIncomeStatement(acc, opts) {
+				return equity("Earnings:Previous")
+			}
+			return acc
+		})
+		summaries := summarize(invs, from.Open.AddDate(0, 0, -1), equity("Opening-Balances"), "Opening balance for '%s' (Summarization)")
+		txs = append(summaries, txs[i:]...)
+	}
+	if !from.Close.IsZero() {
+		i := sort.Search(len(txs), func(i int) bool { return !txs[i].Date.Before(from.Close) })
+		txs = txs[:i]
+	}
+	if from.Clear && len(txs) > 0 {
+		date := txs[len(txs)-1].Date
+		if !from.Close.IsZero() {
+			date = from.Close.AddDate(0, 0, -1)
+		}
+		invs := inventories(txs, func(acc bean.AccountName) bean.AccountName {
+			if isIncomeStatement(acc, opts) {
+				return acc
+			}
+			return ""
+		})
+		// the transfers take the balances to zero
+		for _, inv := range invs {
+			for i, p := range inv.Positions {
+				inv.Positions[i].Units = p.Units.Neg()
+			}
+		}
+		txs = append(txs, summarize(invs, date, equity("Earnings:Current"), "Transfer balance for '%s' (Transfer balance)")...)
+	}
+	return txs, nil
+}
+
+// isIncomeStatement returns true for Income and Expenses accounts
+func isIncomeStatement(acc bean.AccountName, opts bean.Options) bool {
+	root, _, _ := strings.Cut(string(acc), ":")
+	return root == opts.NameIncome || root == opts.NameExpenses
+}
+
+// inventories sums the Postings of txs into an Inventory for each account,
+// after renaming the accounts with rename. Accounts renamed to "" are skipped.
+func inventories(txs []bean.Transaction, rename func(bean.AccountName) bean.AccountName) map[bean.AccountName]*bean.Inventory {
+	invs := map[bean.AccountName]*bean.Inventory{}
+	for i := range txs {
+		for j := range txs[i].Postings {
+			acc := rename(txs[i].Postings[j].Account.Name)
+			if acc == "" {
+				continue
+			}
+			if invs[acc] == nil {
+				invs[acc] = &bean.Inventory{}
+			}
+			pos := position(row{tx: &txs[i], posting: &txs[i].Postings[j]})
+			invs[acc].Add(pos.Units, pos.Lot)
+		}
+	}
+	return invs
+}
+
+// summarize creates a Transaction (with flag S) on date for each
+// Inventory, moving it from the account other
+func summarize(invs map[bean.AccountName]*bean.Inventory, date time.Time, other bean.AccountName, narration string) []bean.Transaction {
+	accs := make([]bean.AccountName, 0, len(invs))
+	for acc, inv := range invs {
+		if !inv.IsEmpty() {
+			accs = append(accs, acc)
+		}
+	}
+	sort.Slice(accs, func(i, j int) bool { return accs[i] < accs[j] })
+	txs := make([]bean.Transaction, len(accs))
+	for i, acc := range accs {
+		tx := bean.Transaction{Date: date, Type: "S", Narration: fmt.Sprintf(narration, acc)}
+		for _, p := range invs[acc].Positions {
+			units, weight := p.Units, p.Units
+			posting := bean.Posting{Account: bean.Account{Name: acc}, Amount: &units}
+			if p.Lot != nil {
+				cost := p.Lot.Cost
+				posting.Cost = &bean.Cost{Amount: &cost, Date: p.Lot.Date, Label: p.Lot.Label}
+				weight = cost.Mul(units.Number)
+			}
+			weight = weight.Neg()
+			tx.Postings = append(tx.Postings, posting, bean.Posting{Account: bean.Account{Name: other}, Amount: &weight})
+		}
+		txs[i] = tx
+	}
+	return txs
+}
+
+// evaluator evaluates expressions, caching the compiled regexps
+type evaluator struct {
+	regexps map[string]*regexp.Regexp
+}
+
+// eval evaluates expr over a group of rows. Aggregates combine
+// all the rows and columns are taken from the first row.
+func (e *evaluator) eval(expr Expr, rows []row) (Value, error) {
+	switch x := expr.(type) {
+	case Literal:
+		return x.Value, nil
+	case ColumnRef:
+		col, ok := columns[x.Name]
+		if !ok {
+			return Null, fmt.Errorf("unknown column %s", x.Name)
+		}
+		if len(rows) == 0 || (col.posting && rows[0].posting == nil) {
+			return Null, nil
+		}
+		return col.get(rows[0]), nil
+	case Call:
+		if agg, ok := aggregates[x.Name]; ok {
+			return e.aggregate(x, agg, rows)
+		}
+		fn, ok := functions[x.Name]
+		if !ok {
+			return Null, fmt.Errorf("unknown function %s", x.Name)
+		}
+		args := make([]Value, len(x.Args))
+		for i, arg := range x.Args {
+			v, err := e.eval(arg, rows)
+			if err != nil {
+				return Null, err
+			}
+			if v.Kind == KindNull {
+				return Null, nil
+			}
+			args[i] = v
+		}
+		return fn.call(args)
+	case Unary:
+		v, err := e.eval(x.Expr, rows)
+		if err != nil {
+			return Null, err
+		}
+		return unary(x.Op, v)
+	case Binary:
+		return e.binary(x, rows)
+	}
+	return Null, fmt.Errorf("unknown expression %v", expr)
+}
+
+// aggregate evaluates the argument of an aggregate for each row
+// and combines the values that aren't NULL
+func (e *evaluator) aggregate(call Call, agg aggregate, rows []row) (Value, error) {
+	if call.Star {
+		return intValue(len(rows)), nil
+	}
+	values := make([]Value, 0, len(rows))
+	for _, r := range rows {
+		v, err := e.eval(call.Args[0], []row{r})
+		if err != nil {
+			return Null, err
+		}
+		if v.Kind != KindNull {
+			values = append(values, v)
+		}
+	}
+	return agg.call(values)
+}
+
+func unary(op string, v Value) (Value, error) {
+	switch op {
+	case "IS NULL":
+		return boolValue(v.Kind == KindNull), nil
+	case "IS NOT NULL":
+		return boolValue(v.Kind != KindNull), nil
+	case "NOT":
+		if v.Kind == KindNull {
+			return Null, nil
+		}
+		return boolValue(!v.truthy()), nil
+	}
+	switch v.Kind {
+	case KindNull:
+		return Null, nil
+	case KindNumber:
+		var d apd.Decimal
+		d.Neg(&v.Number)
+		return numberValue(d), nil
+	case KindAmount:
+		return amountValue(v.Amount.Neg()), nil
+	}
+	return Null, fmt.Errorf("can't apply - to %v", v.Kind)
+}
+
+func (e *evaluator) binary(b Binary, rows []row) (Value, error) {
+	left, err := e.eval(b.Left, rows)
+	if err != nil {
+		return Null, err
+	}
+	switch b.Op {
+	case "AND":
+		if !left.truthy() {
+			return boolValue(false), nil
+		}
+	case "OR":
+		if left.truthy() {
+			return boolValue(true), nil
+		}
+	}
+	right, err := e.eval(b.Right, rows)
+	if err != nil {
+		return Null, err
+	}
+	switch b.Op {
+	case "AND", "OR":
+		return boolValue(right.truthy()), nil
+	}
+	if left.Kind == KindNull || right.Kind == KindNull {
+		return Null, nil
+	}
+	switch b.Op {
+	case "=", "!=", "<", "<=", ">", ">=":
+		if left.Kind != right.Kind {
+			return Null, fmt.Errorf("can't compare %v and %v in %v", left.Kind, right.Kind, b)
+		}
+		c := compare(left, right)
+		ok := map[string]bool{"=": c == 0, "!=": c != 0, "<": c < 0, "<=": c <= 0, ">": c > 0, ">=": c >= 0}
+		return boolValue(ok[b.Op]), nil
+	case "~", "!~":
+		if left.Kind != KindString || right.Kind != KindString {
+			return Null, fmt.Errorf("%s needs strings, found %v and %v", b.Op, left.Kind, right.Kind)
+		}
+		re, err := e.regexp(right.Text)
+		if err != nil {
+			return Null, err
+		}
+		return boolValue(re.MatchString(left.Text) == (b.Op == "~")), nil
+	case "IN":
+		if right.Kind != KindSet {
+			return Null, fmt.Errorf("IN needs a set, found %v", right.Kind)
+		}
+		i := sort.SearchStrings(right.Set, left.String())
+		return boolValue(i < len(right.Set) && right.Set[i] == left.String()), nil
+	}
+	return arithmetic(b.Op, left, right)
+}
+
+// regexp compiles a case-insensitive pattern, which can match anywhere
+func (e *evaluator) regexp(pattern string) (*regexp.Regexp, error) {
+	if re, ok := e.regexps[pattern]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile("(?i)" + pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regexp %q: %w", pattern, err)
+	}
+	e.regexps[pattern] = re
+	return re, nil
+}
+
+// arithmetic applies + - * / to numbers, and * / to an amount and a number
+func arithmetic(op string, left Value, right Value) (Value, error) {
+	if left.Kind == KindAmount && right.Kind == KindNumber && (op == "*" || op == "/") {
+		if op == "*" {
+			return amountValue(left.Amount.Mul(right.Number)), nil
+		}
+		if right.Number.IsZero() {
+			return Null, nil
+		}
+		amt, err := left.Amount.Quo(right.Number)
+		return amountValue(amt), err
+	}
+	if left.Kind != KindNumber || right.Kind != KindNumber {
+		return Null, fmt.Errorf("can't apply %s to %v and %v", op, left.Kind, right.Kind)
+	}
+	var d apd.Decimal
+	var err error
+	switch op {
+	case "+":
+		_, err = apdCtx.Add(&d, &left.Number, &right.Number)
+	case "-":
+		_, err = apdCtx.Sub(&d, &left.Number, &right.Number)
+	case "*":
+		_, err = apdCtx.Mul(&d, &left.Number, &right.Number)
+	case "/":
+		if right.Number.IsZero() {
+			return Null, nil
+		}
+		_, err = apdCtx.Quo(&d, &left.Number, &right.Number)
+	default:
+		return Null, fmt.Errorf("unknown operator %s", op)
+	}
+	if err != nil {
+		return Null, fmt.Errorf("in arithmetic: %w", err)
+	}
+	return numberValue(d), nil
+}
+
+// typeOf returns the Kind of the values of expr,
+// or an error if it uses unknown columns or functions
+func typeOf(expr Expr) (Kind, error) {
+	switch x := expr.(type) {
+	case Literal:
+		return x.Value.Kind, nil
+	case ColumnRef:
+		col, ok := columns[x.Name]
+		if !ok {
+			return KindNull, fmt.Errorf("unknown column %s", x.Name)
+		}
+		return col.kind, nil
+	case Call:
+		kinds := make([]Kind, len(x.Args))
+		for i, arg := range x.Args {
+			k, err := typeOf(arg)
+			if err != nil {
+				return KindNull, err
+			}
+			kinds[i] = k
+		}
+		if agg, ok := aggregates[x.Name]; ok {
+			if x.Star {
+				if x.Name != "count" {
+					return KindNull, fmt.Errorf("only count can be applied to *")
+				}
+				return KindNumber, nil
+			}
+			if len(x.Args) != 1 {
+				return KindNull, fmt.Errorf("%s needs 1 argument, found %d", x.Name, len(x.Args))
+			}
+			if hasAggregate(x.Args[0]) {
+				return KindNull, fmt.Errorf("aggregates can't be nested: %v", x)
+			}
+			return agg.kind(kinds[0]), nil
+		}
+		fn, ok := functions[x.Name]
+		if !ok {
+			return KindNull, fmt.Errorf("unknown function %s", x.Name)
+		}
+		if x.Star || len(x.Args) != fn.args {
+			return KindNull, fmt.Errorf("%s needs %d arguments, found %d", x.Name, fn.args, len(x.Args))
+		}
+		return fn.kind(kinds), nil
+	case Unary:
+		k, err := typeOf(x.Expr)
+		if err != nil || x.Op == "-" {
+			return k, err
+		}
+		return KindBool, nil
+	case Binary:
+		left, err := typeOf(x.Left)
+		if err != nil {
+			return KindNull, err
+		}
+		if _, err := typeOf(x.Right); err != nil {
+			return KindNull, err
+		}
+		switch x.Op {
+		case "+", "-", "*", "/":
+			if left == KindAmount {
+				return KindAmount, nil
+			}
+			return KindNumber, nil
+		}
+		return KindBool, nil
+	}
+	return KindNull, fmt.Errorf("unknown expression %v", expr)
+}
+
+// hasAggregate returns true if expr contains an aggregate function
+func hasAggregate(expr Expr) bool {
+	switch x := expr.(type) {
+	case Call:
+		if _, ok := aggregates[x.Name]; ok {
+			return true
+		}
+		for _, arg := range x.Args {
+			if hasAggregate(arg) {
+				return true
+			}
+		}
+	case Unary:
+		return hasAggregate(x.Expr)
+	case Binary:
+		return hasAggregate(x.Left) || hasAggregate(x.Right)
+	}
+	return false
+}
+
+// usesPosting returns true if expr uses a column of the Posting
+func usesPosting(expr Expr) bool {
+	switch x := expr.(type) {
+	case ColumnRef:
+		return columns[x.Name].posting
+	case Call:
+		for _, arg := range x.Args {
+			if usesPosting(arg) {
+				return true
+			}
+		}
+	case Unary:
+		return usesPosting(x.Expr)
+	case Binary:
+		return usesPosting(x.Left) || usesPosting(x.Right)
+	}
+	return false
+}
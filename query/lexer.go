@@ -0,0 +1,110 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+)
+
+// tokenKind is the type of a query token
+type tokenKind int
+
+const (
+	tokEOF    tokenKind = iota
+	tokIdent            // names and keywords
+	tokString           // 'quoted' or "quoted"
+	tokNumber           // 12.50
+	tokDate             // 2023-01-01
+	tokOp               // operators and punctuation
+)
+
+// token is a lexed piece of a query
+type token struct {
+	kind tokenKind
+	text string
+	pos  int // byte offset in the query
+}
+
+func (t token) String() string {
+	if t.kind == tokEOF {
+		return "end of query"
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// is returns true if t is the keyword or operator text (case insensitive)
+func (t token) is(text string) bool {
+	return (t.kind == tokIdent || t.kind == tokOp) && strings.EqualFold(t.text, text)
+}
+
+// twoCharOps are the operators with two characters
+var twoCharOps = []string{"!=", "<>", "<=", ">=", "!~"}
+
+// lexQuery splits a query into tokens, ending with tokEOF
+func lexQuery(q string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(q); {
+		c := q[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '\'' || c == '"':
+			end := strings.IndexByte(q[i+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string at %d", i)
+			}
+			tokens = append(tokens, token{tokString, q[i+1 : i+1+end], i})
+			i += end + 2
+		case isDigit(c):
+			if isDate(q[i:]) {
+				tokens = append(tokens, token{tokDate, q[i : i+10], i})
+				i += 10
+				continue
+			}
+			start := i
+			for i < len(q) && (isDigit(q[i]) || q[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{tokNumber, q[start:i], start})
+		case isIdentStart(c):
+			start := i
+			for i < len(q) && (isIdentStart(q[i]) || isDigit(q[i])) {
+				i++
+			}
+			tokens = append(tokens, token{tokIdent, q[start:i], start})
+		default:
+			op := string(c)
+			for _, two := range twoCharOps {
+				if strings.HasPrefix(q[i:], two) {
+					op = two
+				}
+			}
+			if !strings.Contains("=!<>~+-*/(),;", string(c)) {
+				return nil, fmt.Errorf("unexpected character %q at %d", c, i)
+			}
+			tokens = append(tokens, token{tokOp, op, i})
+			i += len(op)
+		}
+	}
+	return append(tokens, token{kind: tokEOF, pos: len(q)}), nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// isDate returns true if s starts with YYYY-MM-DD
+func isDate(s string) bool {
+	if len(s) < 10 || s[4] != '-' || s[7] != '-' {
+		return false
+	}
+	for _, i := range [...]int{0, 1, 2, 3, 5, 6, 8, 9} {
+		if !isDigit(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
@@ -0,0 +1,196 @@
+package query
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/carderne/gobean/bean"
+)
+
+// Format is a way of writing a Result
+type Format string
+
+// Output formats
+const (
+	FormatTable Format = "table"
+	FormatCSV   Format = "csv"
+	FormatJSON  Format = "json"
+)
+
+// NewFormat checks that str is a known Format
+func NewFormat(str string) (Format, error) {
+	switch f := Format(str); f {
+	case FormatTable, FormatCSV, FormatJSON:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown format %q: must be table, csv or json", str)
+}
+
+// Print writes the Result to w in the given Format
+func Print(w io.Writer, res *Result, format Format) error {
+	switch format {
+	case FormatCSV:
+		return PrintCSV(w, res)
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(res)
+	}
+	return PrintTable(w, res)
+}
+
+// PrintTable writes the Result as a table with aligned columns,
+// with numbers and amounts aligned to the right
+func PrintTable(w io.Writer, res *Result) error {
+	widths := make([]int, len(res.Columns))
+	for i, c := range res.Columns {
+		widths[i] = len(c.Name)
+	}
+	cells := make([][]string, len(res.Rows))
+	for i, r := range res.Rows {
+		cells[i] = make([]string, len(r))
+		for j, v := range r {
+			cells[i][j] = v.String()
+			widths[j] = max(widths[j], len([]rune(cells[i][j])))
+		}
+	}
+	line := func(values []string) error {
+		parts := make([]string, len(values))
+		for i, v := range values {
+			pad := strings.Repeat(" ", widths[i]-len([]rune(v)))
+			switch res.Columns[i].Kind {
+			case KindNumber, KindAmount, KindPosition, KindInventory:
+				parts[i] = pad + v
+			default:
+				parts[i] = v + pad
+			}
+		}
+		_, err := fmt.Fprintln(w, strings.TrimRight(strings.Join(parts, "  "), " "))
+		return err
+	}
+	names := make([]string, len(res.Columns))
+	rules := make([]string, len(res.Columns))
+	for i, c := range res.Columns {
+		names[i] = c.Name
+		rules[i] = strings.Repeat("-", widths[i])
+	}
+	for _, values := range append([][]string{names, rules}, cells...) {
+		if err := line(values); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PrintCSV writes the Result as CSV with a header row
+func PrintCSV(w io.Writer, res *Result) error {
+	cw := csv.NewWriter(w)
+	record := make([]string, len(res.Columns))
+	for i, c := range res.Columns {
+		record[i] = c.Name
+	}
+	if err := cw.Write(record); err != nil {
+		return err
+	}
+	for _, r := range res.Rows {
+		for i, v := range r {
+			record[i] = v.String()
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// jsonPosition is a Position in JSON
+type jsonPosition struct {
+	Units     bean.Amount  `json:"units"`
+	Cost      *bean.Amount `json:"cost,omitempty"` // per unit
+	CostDate  string       `json:"cost_date,omitempty"`
+	CostLabel string       `json:"cost_label,omitempty"`
+}
+
+func newJSONPosition(p bean.Position) jsonPosition {
+	res := jsonPosition{Units: p.Units}
+	if p.Lot != nil {
+		cost := p.Lot.Cost
+		res.Cost = &cost
+		res.CostDate = p.Lot.Date.Format(time.DateOnly)
+		res.CostLabel = p.Lot.Label
+	}
+	return res
+}
+
+// MarshalJSON renders the Value as the matching JSON type.
+// Amounts are objects with a number and currency, as in the rest
+// of the JSON output, Inventories are arrays of Positions
+// and Sets are arrays of strings.
+func (v Value) MarshalJSON() ([]byte, error) {
+	switch v.Kind {
+	case KindNull:
+		return []byte("null"), nil
+	case KindBool:
+		return json.Marshal(v.Bool)
+	case KindNumber:
+		return []byte(v.Number.Text('f')), nil
+	case KindString, KindDate:
+		return json.Marshal(v.String())
+	case KindAmount:
+		return json.Marshal(v.Amount)
+	case KindPosition:
+		return json.Marshal(newJSONPosition(v.Position))
+	case KindInventory:
+		positions := make([]jsonPosition, len(v.Inventory.Positions))
+		for i, p := range v.Inventory.Positions {
+			positions[i] = newJSONPosition(p)
+		}
+		return json.Marshal(positions)
+	case KindSet:
+		set := v.Set
+		if set == nil {
+			set = []string{}
+		}
+		return json.Marshal(set)
+	}
+	return nil, fmt.Errorf("unknown kind %v", v.Kind)
+}
+
+// Columns returns the columns that can be used in queries, sorted by name
+func Columns() []Column {
+	res := make([]Column, 0, len(columns))
+	for name, c := range columns {
+		res = append(res, Column{Name: name, Kind: c.kind})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res
+}
+
+// Functions returns the names of the scalar and aggregate functions, sorted
+func Functions() (scalar []string, aggregate []string) {
+	for name := range functions {
+		scalar = append(scalar, name)
+	}
+	for name := range aggregates {
+		aggregate = append(aggregate, name)
+	}
+	sort.Strings(scalar)
+	sort.Strings(aggregate)
+	return scalar, aggregate
+}
+
+// Named returns the query directive called name
+func Named(l *bean.Ledger, name string) (bean.Query, bool) {
+	for _, q := range l.Queries {
+		if q.Name == name {
+			return q, true
+		}
+	}
+	return bean.Query{}, false
+}
@@ -0,0 +1,97 @@
+package query
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPrint(t *testing.T) {
+	res, err := Run(testLedger(t), "SELECT account, sum(position) AS total, tags WHERE account ~ 'Broker|Salary' GROUP BY account, tags")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		format Format
+		want   string
+	}{
+		{FormatTable, `account                              total  tags
+-------------  ---------------------------  ----
+Income:Salary                    -1000 GBP  work
+Assets:Broker  2 FOO {100 GBP, 2023-02-01}
+`},
+		{FormatCSV, `account,total,tags
+Income:Salary,-1000 GBP,work
+Assets:Broker,"2 FOO {100 GBP, 2023-02-01}",
+`},
+		{FormatJSON, `{
+  "columns": [
+    {
+      "name": "account",
+      "kind": "string"
+    },
+    {
+      "name": "total",
+      "kind": "inventory"
+    },
+    {
+      "name": "tags",
+      "kind": "set"
+    }
+  ],
+  "rows": [
+    [
+      "Income:Salary",
+      [
+        {
+          "units": {
+            "number": "-1000",
+            "currency": "GBP"
+          }
+        }
+      ],
+      [
+        "work"
+      ]
+    ],
+    [
+      "Assets:Broker",
+      [
+        {
+          "units": {
+            "number": "2",
+            "currency": "FOO"
+          },
+          "cost": {
+            "number": "100",
+            "currency": "GBP"
+          },
+          "cost_date": "2023-02-01"
+        }
+      ],
+      []
+    ]
+  ]
+}
+`},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			var b strings.Builder
+			if err := Print(&b, res, tt.format); err != nil {
+				t.Fatal(err)
+			}
+			if got := b.String(); got != tt.want {
+				t.Errorf("want\n%s\ngot\n%s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestNewFormat(t *testing.T) {
+	if _, err := NewFormat("csv"); err != nil {
+		t.Error(err)
+	}
+	if _, err := NewFormat("xml"); err == nil {
+		t.Error("invalid format should error")
+	}
+}
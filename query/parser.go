@@ -0,0 +1,512 @@
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/apd/v3"
+)
+
+// Expr is an expression in a query
+type Expr interface {
+	fmt.Stringer
+}
+
+// ColumnRef is a reference to a column, eg account
+type ColumnRef struct {
+	Name string
+}
+
+func (c ColumnRef) String() string { return c.Name }
+
+// Literal is a constant, eg 'Expenses' or 2023-01-01
+type Literal struct {
+	Value Value
+}
+
+func (l Literal) String() string {
+	if l.Value.Kind == KindString {
+		return strconv.Quote(l.Value.Text)
+	}
+	if l.Value.Kind == KindNull {
+		return "NULL"
+	}
+	return l.Value.String()
+}
+
+// Call is a function call, eg sum(position)
+type Call struct {
+	Name string // lower case
+	Args []Expr
+	Star bool // count(*)
+}
+
+func (c Call) String() string {
+	if c.Star {
+		return c.Name + "(*)"
+	}
+	args := make([]string, len(c.Args))
+	for i, a := range c.Args {
+		args[i] = a.String()
+	}
+	return c.Name + "(" + strings.Join(args, ", ") + ")"
+}
+
+// Binary is an operator with two operands, eg account ~ 'Expenses'
+type Binary struct {
+	Op    string // upper case for keywords, eg AND, IN
+	Left  Expr
+	Right Expr
+}
+
+func (b Binary) String() string {
+	return fmt.Sprintf("%v %s %v", b.Left, b.Op, b.Right)
+}
+
+// Unary is an operator with one operand: -, NOT, IS NULL or IS NOT NULL
+type Unary struct {
+	Op   string
+	Expr Expr
+}
+
+func (u Unary) String() string {
+	switch u.Op {
+	case "-":
+		return "-" + u.Expr.String()
+	case "NOT":
+		return "NOT " + u.Expr.String()
+	}
+	return u.Expr.String() + " " + u.Op
+}
+
+// Target is an expression in the SELECT list
+type Target struct {
+	Expr Expr
+	Name string // from AS, or the expression text
+}
+
+// From selects the Transactions that postings come from
+type From struct {
+	Expr  Expr      // nil if not given
+	Open  time.Time // zero if not given
+	Close time.Time // zero if not given
+	Clear bool
+}
+
+// OrderTerm is an expression in the ORDER BY list
+type OrderTerm struct {
+	Expr Expr
+	Desc bool
+}
+
+// Select is a parsed query
+type Select struct {
+	Distinct bool
+	Targets  []Target
+	From     From
+	Where    Expr // nil if not given
+	GroupBy  []Expr
+	OrderBy  []OrderTerm
+	Limit    int // 0 for no limit
+}
+
+// keywords can't be used as column names
+var keywords = map[string]bool{
+	"SELECT": true, "DISTINCT": true, "FROM": true, "WHERE": true, "GROUP": true, "ORDER": true,
+	"BY": true, "LIMIT": true, "AS": true, "ASC": true, "DESC": true, "AND": true, "OR": true,
+	"NOT": true, "IN": true, "IS": true, "NULL": true, "TRUE": true, "FALSE": true,
+	"OPEN": true, "CLOSE": true, "CLEAR": true, "ON": true,
+}
+
+// defaultTargets are the columns for SELECT *
+var defaultTargets = []string{"date", "flag", "payee", "narration", "account", "position"}
+
+// parser parses a list of tokens
+type parser struct {
+	tokens []token
+	i      int
+}
+
+// Parse parses a query of the form:
+//
+//	SELECT [DISTINCT] targets
+//	[FROM [expr] [OPEN ON date] [CLOSE ON date] [CLEAR]]
+//	[WHERE expr] [GROUP BY exprs] [ORDER BY exprs [ASC|DESC]] [LIMIT n]
+func Parse(q string) (*Select, error) {
+	tokens, err := lexQuery(q)
+	if err != nil {
+		return nil, fmt.Errorf("in Parse: %w", err)
+	}
+	p := &parser{tokens: tokens}
+	sel, err := p.parseSelect()
+	if err != nil {
+		return nil, fmt.Errorf("in Parse: %w", err)
+	}
+	return sel, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.i]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+// accept consumes the next token if it is text
+func (p *parser) accept(text string) bool {
+	if p.peek().is(text) {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(text string) error {
+	if !p.accept(text) {
+		return p.unexpected(text)
+	}
+	return nil
+}
+
+func (p *parser) unexpected(want string) error {
+	t := p.peek()
+	return fmt.Errorf("expected %s but found %v at %d", want, t, t.pos)
+}
+
+func (p *parser) parseSelect() (*Select, error) {
+	if err := p.expect("SELECT"); err != nil {
+		return nil, err
+	}
+	sel := &Select{Distinct: p.accept("DISTINCT")}
+	if p.accept("*") {
+		for _, name := range defaultTargets {
+			sel.Targets = append(sel.Targets, Target{ColumnRef{name}, name})
+		}
+	} else {
+		for {
+			expr, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			target := Target{Expr: expr, Name: expr.String()}
+			if p.accept("AS") {
+				t := p.next()
+				if t.kind != tokIdent {
+					return nil, fmt.Errorf("expected a name after AS but found %v", t)
+				}
+				target.Name = t.text
+			}
+			sel.Targets = append(sel.Targets, target)
+			if !p.accept(",") {
+				break
+			}
+		}
+	}
+
+	var err error
+	if p.accept("FROM") {
+		if err := p.parseFrom(&sel.From); err != nil {
+			return nil, err
+		}
+	}
+	if p.accept("WHERE") {
+		if sel.Where, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+	}
+	if p.accept("GROUP") {
+		if err := p.expect("BY"); err != nil {
+			return nil, err
+		}
+		for {
+			expr, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			sel.GroupBy = append(sel.GroupBy, expr)
+			if !p.accept(",") {
+				break
+			}
+		}
+	}
+	if p.accept("ORDER") {
+		if err := p.expect("BY"); err != nil {
+			return nil, err
+		}
+		for {
+			expr, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			term := OrderTerm{Expr: expr}
+			if p.accept("DESC") {
+				term.Desc = true
+			} else {
+				p.accept("ASC")
+			}
+			sel.OrderBy = append(sel.OrderBy, term)
+			if !p.accept(",") {
+				break
+			}
+		}
+	}
+	if p.accept("LIMIT") {
+		t := p.next()
+		n, err := strconv.Atoi(t.text)
+		if t.kind != tokNumber || err != nil || n < 1 {
+			return nil, fmt.Errorf("LIMIT must be a positive integer, found %v", t)
+		}
+		sel.Limit = n
+	}
+	p.accept(";")
+	if p.peek().kind != tokEOF {
+		return nil, p.unexpected("end of query")
+	}
+	return sel, nil
+}
+
+// parseFrom parses: [expr] [OPEN ON date] [CLOSE ON date] [CLEAR]
+func (p *parser) parseFrom(from *From) error {
+	if !p.peek().is("OPEN") && !p.peek().is("CLOSE") && !p.peek().is("CLEAR") {
+		expr, err := p.parseExpr()
+		if err != nil {
+			return err
+		}
+		from.Expr = expr
+	}
+	parseOn := func() (time.Time, error) {
+		if err := p.expect("ON"); err != nil {
+			return time.Time{}, err
+		}
+		t := p.next()
+		if t.kind != tokDate {
+			return time.Time{}, fmt.Errorf("expected a date but found %v", t)
+		}
+		return time.Parse(time.DateOnly, t.text)
+	}
+	var err error
+	if p.accept("OPEN") {
+		if from.Open, err = parseOn(); err != nil {
+			return err
+		}
+	}
+	if p.accept("CLOSE") {
+		if from.Close, err = parseOn(); err != nil {
+			return err
+		}
+	}
+	from.Clear = p.accept("CLEAR")
+	return nil
+}
+
+func (p *parser) parseExpr() (Expr, error) {
+	return p.parseOr()
+}
+
+func (p *parser) parseOr() (Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("OR") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = Binary{"OR", left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("AND") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = Binary{"AND", left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (Expr, error) {
+	if p.accept("NOT") {
+		expr, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return Unary{"NOT", expr}, nil
+	}
+	return p.parseComparison()
+}
+
+// comparisons are the operators between two sums
+var comparisons = []string{"=", "!=", "<>", "<", "<=", ">", ">=", "~", "!~"}
+
+func (p *parser) parseComparison() (Expr, error) {
+	left, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range comparisons {
+		if p.accept(op) {
+			right, err := p.parseSum()
+			if err != nil {
+				return nil, err
+			}
+			if op == "<>" {
+				op = "!="
+			}
+			return Binary{op, left, right}, nil
+		}
+	}
+	if p.accept("IS") {
+		op := "IS NULL"
+		if p.accept("NOT") {
+			op = "IS NOT NULL"
+		}
+		if err := p.expect("NULL"); err != nil {
+			return nil, err
+		}
+		return Unary{op, left}, nil
+	}
+	not := p.peek().is("NOT") && p.tokens[p.i+1].is("IN")
+	if not {
+		p.next()
+	}
+	if p.accept("IN") {
+		right, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		var expr Expr = Binary{"IN", left, right}
+		if not {
+			expr = Unary{"NOT", expr}
+		}
+		return expr, nil
+	}
+	return left, nil
+}
+
+func (p *parser) parseSum() (Expr, error) {
+	left, err := p.parseProduct()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := p.peek().text
+		if p.peek().kind != tokOp || (op != "+" && op != "-") {
+			return left, nil
+		}
+		p.next()
+		right, err := p.parseProduct()
+		if err != nil {
+			return nil, err
+		}
+		left = Binary{op, left, right}
+	}
+}
+
+func (p *parser) parseProduct() (Expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := p.peek().text
+		if p.peek().kind != tokOp || (op != "*" && op != "/") {
+			return left, nil
+		}
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = Binary{op, left, right}
+	}
+}
+
+func (p *parser) parseUnary() (Expr, error) {
+	if p.accept("-") {
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return Unary{"-", expr}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (Expr, error) {
+	t := p.next()
+	switch t.kind {
+	case tokString:
+		return Literal{stringValue(t.text)}, nil
+	case tokNumber:
+		d, _, err := apd.NewFromString(t.text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %s: %w", t.text, err)
+		}
+		return Literal{numberValue(*d)}, nil
+	case tokDate:
+		date, err := time.Parse(time.DateOnly, t.text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid date %s: %w", t.text, err)
+		}
+		return Literal{dateValue(date)}, nil
+	case tokOp:
+		if t.text == "(" {
+			expr, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			return expr, p.expect(")")
+		}
+	case tokIdent:
+		switch upper := strings.ToUpper(t.text); upper {
+		case "TRUE", "FALSE":
+			return Literal{boolValue(upper == "TRUE")}, nil
+		case "NULL":
+			return Literal{Null}, nil
+		}
+		if keywords[strings.ToUpper(t.text)] {
+			break
+		}
+		name := strings.ToLower(t.text)
+		if !p.accept("(") {
+			return ColumnRef{name}, nil
+		}
+		call := Call{Name: name}
+		if p.accept("*") {
+			call.Star = true
+			return call, p.expect(")")
+		}
+		if p.accept(")") {
+			return call, nil
+		}
+		for {
+			arg, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			call.Args = append(call.Args, arg)
+			if !p.accept(",") {
+				break
+			}
+		}
+		return call, p.expect(")")
+	}
+	p.i--
+	return nil, p.unexpected("an expression")
+}
@@ -0,0 +1,221 @@
+package query
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/carderne/gobean/bean"
+	"github.com/google/go-cmp/cmp"
+)
+
+// testLedger loads the Ledger that the queries run against
+func testLedger(t *testing.T) *bean.Ledger {
+	l, err := bean.NewLedger(false).Load(io.NopCloser(strings.NewReader(`
+option "operating_currency" "GBP"
+2022-01-01 open Assets:Bank GBP
+2022-01-01 open Assets:Broker
+2022-01-01 open Income:Salary GBP
+2022-01-01 open Expenses:Food GBP
+2022-01-01 open Expenses:Rent GBP
+2022-01-01 open Equity:Opening-Balances
+
+2022-06-01 * "Employer" "Salary" #work
+  Income:Salary  -1000 GBP
+  Assets:Bank
+
+2022-06-02 * "Shop" "Groceries"
+  Expenses:Food  50 GBP
+  Assets:Bank
+
+2023-01-05 * "Landlord" "Rent" ^lease
+  Expenses:Rent  400 GBP
+  Assets:Bank
+
+2023-01-06 * "Shop" "Groceries"
+  Expenses:Food  20 GBP
+  Assets:Bank
+
+2023-02-01 * "Broker" "Buy shares"
+  Assets:Broker  2 FOO {100 GBP}
+  Assets:Bank
+`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+// strs returns the Rows as strings
+func strs(res *Result) [][]string {
+	rows := make([][]string, len(res.Rows))
+	for i, r := range res.Rows {
+		rows[i] = make([]string, len(r))
+		for j, v := range r {
+			rows[i][j] = v.String()
+		}
+	}
+	return rows
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		q       string
+		want    string
+		wantErr bool
+	}{
+		{q: "SELECT account", want: "account"},
+		{q: "select Account AS acc, sum(position)", want: "account|sum(position)"},
+		{q: "SELECT *", want: "date|flag|payee|narration|account|position"},
+		{q: "SELECT -number * 2 + 1", want: "-number * 2 + 1"},
+		{q: "SELECT count(*)", want: "count(*)"},
+		{q: "SELECT account WHERE", wantErr: true},
+		{q: "SELECT account FROM", wantErr: true},
+		{q: "SELECT account LIMIT 0", wantErr: true},
+		{q: "SELECT 'unterminated", wantErr: true},
+		{q: "SELECT account extra", wantErr: true},
+		{q: "account", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.q, func(t *testing.T) {
+			sel, err := Parse(tt.q)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("%s: want error %v, got %v", tt.q, tt.wantErr, err)
+			}
+			if err != nil {
+				return
+			}
+			var exprs []string
+			for _, target := range sel.Targets {
+				exprs = append(exprs, target.Expr.String())
+			}
+			if got := strings.Join(exprs, "|"); got != tt.want {
+				t.Errorf("%s: want %s, got %s", tt.q, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParseClauses(t *testing.T) {
+	sel, err := Parse(`SELECT DISTINCT account AS acc FROM year = 2023 OPEN ON 2023-01-01 CLOSE ON 2024-01-01 CLEAR
+		WHERE account ~ 'Expenses' AND NOT 'x' IN tags GROUP BY 1 ORDER BY acc DESC, 1 LIMIT 5;`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{
+		sel.Targets[0].Name,
+		sel.From.Expr.String(),
+		sel.From.Open.Format("2006-01-02"),
+		sel.From.Close.Format("2006-01-02"),
+		sel.Where.String(),
+		sel.GroupBy[0].String(),
+		sel.OrderBy[0].Expr.String(),
+	}
+	want := []string{"acc", "year = 2023", "2023-01-01", "2024-01-01", `account ~ "Expenses" AND NOT "x" IN tags`, "1", "acc"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Error(diff)
+	}
+	if !sel.Distinct || !sel.From.Clear || !sel.OrderBy[0].Desc || sel.OrderBy[1].Desc || sel.Limit != 5 {
+		t.Errorf("incorrect flags: %+v", sel)
+	}
+}
+
+func TestRun(t *testing.T) {
+	l := testLedger(t)
+	tests := []struct {
+		name    string
+		q       string
+		want    [][]string
+		wantErr bool
+	}{
+		{
+			name: "group and order",
+			q:    "SELECT account, sum(position) FROM year = 2023 WHERE account ~ 'expenses' GROUP BY account ORDER BY 2 DESC",
+			want: [][]string{{"Expenses:Rent", "400 GBP"}, {"Expenses:Food", "20 GBP"}},
+		},
+		{
+			name: "implicit group",
+			q:    "SELECT root(account, 1) AS root, count(*), sum(number) WHERE currency = 'GBP' ORDER BY root",
+			want: [][]string{{"Assets", "5", "330"}, {"Expenses", "3", "470"}, {"Income", "1", "-1000"}},
+		},
+		{
+			name: "columns",
+			q:    "SELECT date, flag, payee, narration, tags, links, position, cost, weight WHERE account = 'Assets:Broker'",
+			want: [][]string{{"2023-02-01", "*", "Broker", "Buy shares", "", "", "2 FOO {100 GBP, 2023-02-01}", "200 GBP", "200 GBP"}},
+		},
+		{
+			name: "sets and functions",
+			q:    "SELECT DISTINCT leaf(account), parent(account) WHERE 'work' IN tags OR 'lease' IN links",
+			want: [][]string{{"Salary", "Income"}, {"Bank", "Assets"}, {"Rent", "Expenses"}},
+		},
+		{
+			name: "inventory",
+			q:    "SELECT sum(position), units(sum(position)), cost(sum(position)) WHERE account ~ '^Assets'",
+			want: [][]string{{"330 GBP, 2 FOO {100 GBP, 2023-02-01}", "330 GBP, 2 FOO", "530 GBP"}},
+		},
+		{
+			name: "limit and null",
+			q:    "SELECT narration, cost IS NULL ORDER BY date DESC LIMIT 1",
+			want: [][]string{{"Buy shares", "FALSE"}},
+		},
+		{
+			name: "open",
+			q:    "SELECT date, flag, account, sum(position) FROM OPEN ON 2023-01-01 WHERE date < 2023-01-01 GROUP BY date, flag, account",
+			want: [][]string{
+				{"2022-12-31", "S", "Assets:Bank", "950 GBP"},
+				{"2022-12-31", "S", "Equity:Opening-Balances", ""},
+				{"2022-12-31", "S", "Equity:Earnings:Previous", "-950 GBP"},
+			},
+		},
+		{
+			name: "close and clear",
+			q:    "SELECT account, sum(position) FROM CLOSE ON 2023-01-06 CLEAR WHERE account ~ '^(Income|Expenses|Equity)' GROUP BY account ORDER BY account",
+			want: [][]string{
+				{"Equity:Earnings:Current", "-550 GBP"},
+				{"Expenses:Food", ""},
+				{"Expenses:Rent", ""},
+				{"Income:Salary", ""},
+			},
+		},
+		{name: "unknown column", q: "SELECT foo", wantErr: true},
+		{name: "unknown function", q: "SELECT foo(account)", wantErr: true},
+		{name: "posting column in FROM", q: "SELECT date FROM account = 'x'", wantErr: true},
+		{name: "aggregate in WHERE", q: "SELECT date WHERE count(*) > 1", wantErr: true},
+		{name: "not grouped", q: "SELECT date, sum(position) GROUP BY account", wantErr: true},
+		{name: "bad comparison", q: "SELECT date WHERE date = 1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := Run(l, tt.q)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("want error %v, got %v", tt.wantErr, err)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tt.want, strs(res)); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestRunColumns(t *testing.T) {
+	res, err := Run(testLedger(t), "SELECT date, account AS acc, sum(position), sum(number), count(*) GROUP BY 1, 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Column{
+		{"date", KindDate},
+		{"acc", KindString},
+		{"sum(position)", KindInventory},
+		{"sum(number)", KindNumber},
+		{"count(*)", KindNumber},
+	}
+	if diff := cmp.Diff(want, res.Columns); diff != "" {
+		t.Error(diff)
+	}
+	if len(res.Rows) != 10 {
+		t.Errorf("want 10 rows, got %d", len(res.Rows))
+	}
+}
@@ -0,0 +1,146 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/carderne/gobean/bean"
+	"github.com/cockroachdb/apd/v3"
+)
+
+// Kind is the type of a Value or Column
+type Kind int
+
+// Types of values
+const (
+	KindNull Kind = iota
+	KindBool
+	KindNumber
+	KindString
+	KindDate
+	KindAmount
+	KindPosition
+	KindInventory
+	KindSet
+)
+
+var kindNames = [...]string{"null", "bool", "number", "string", "date", "amount", "position", "inventory", "set"}
+
+func (k Kind) String() string {
+	if int(k) < len(kindNames) {
+		return kindNames[k]
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+// MarshalText renders the Kind as its name, eg in JSON
+func (k Kind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// Value is a typed value in a Row.
+// Only the field matching Kind is set.
+type Value struct {
+	Kind      Kind
+	Bool      bool
+	Number    apd.Decimal
+	Text      string
+	Date      time.Time
+	Amount    bean.Amount
+	Position  bean.Position
+	Inventory *bean.Inventory
+	Set       []string // sorted
+}
+
+// Null is the Value of missing data
+var Null = Value{}
+
+func boolValue(b bool) Value          { return Value{Kind: KindBool, Bool: b} }
+func numberValue(d apd.Decimal) Value { return Value{Kind: KindNumber, Number: d} }
+func stringValue(s string) Value      { return Value{Kind: KindString, Text: s} }
+func dateValue(t time.Time) Value     { return Value{Kind: KindDate, Date: t} }
+func amountValue(a bean.Amount) Value { return Value{Kind: KindAmount, Amount: a} }
+func setValue(s bean.Set) Value       { return Value{Kind: KindSet, Set: s.Sorted()} }
+func intValue(i int) Value            { return numberValue(*apd.New(int64(i), 0)) }
+
+func positionValue(p bean.Position) Value {
+	return Value{Kind: KindPosition, Position: p}
+}
+
+func inventoryValue(inv *bean.Inventory) Value {
+	return Value{Kind: KindInventory, Inventory: inv}
+}
+
+// String returns the Value as it should be displayed
+func (v Value) String() string {
+	switch v.Kind {
+	case KindBool:
+		if v.Bool {
+			return "TRUE"
+		}
+		return "FALSE"
+	case KindNumber:
+		return v.Number.Text('f')
+	case KindString:
+		return v.Text
+	case KindDate:
+		return v.Date.Format(time.DateOnly)
+	case KindAmount:
+		return v.Amount.String()
+	case KindPosition:
+		return v.Position.String()
+	case KindInventory:
+		parts := make([]string, len(v.Inventory.Positions))
+		for i, p := range v.Inventory.Positions {
+			parts[i] = p.String()
+		}
+		return strings.Join(parts, ", ")
+	case KindSet:
+		return strings.Join(v.Set, ",")
+	}
+	return ""
+}
+
+// key returns a string that is equal for equal Values, for grouping
+func (v Value) key() string {
+	return fmt.Sprintf("%d:%s", v.Kind, v.String())
+}
+
+// truthy returns true for TRUE, and false for everything else (including NULL)
+func (v Value) truthy() bool {
+	return v.Kind == KindBool && v.Bool
+}
+
+// compare orders Values of the same Kind, with NULLs first.
+// Values of different Kinds are ordered by Kind.
+func compare(a Value, b Value) int {
+	if a.Kind != b.Kind {
+		return int(a.Kind) - int(b.Kind)
+	}
+	switch a.Kind {
+	case KindBool:
+		switch {
+		case a.Bool == b.Bool:
+			return 0
+		case a.Bool:
+			return 1
+		}
+		return -1
+	case KindNumber:
+		return a.Number.Cmp(&b.Number)
+	case KindDate:
+		return a.Date.Compare(b.Date)
+	case KindAmount:
+		if a.Amount.Ccy != b.Amount.Ccy {
+			return strings.Compare(string(a.Amount.Ccy), string(b.Amount.Ccy))
+		}
+		return a.Amount.Number.Cmp(&b.Amount.Number)
+	case KindPosition:
+		if a.Position.Units.Ccy != b.Position.Units.Ccy {
+			return strings.Compare(string(a.Position.Units.Ccy), string(b.Position.Units.Ccy))
+		}
+		return a.Position.Units.Number.Cmp(&b.Position.Units.Number)
+	}
+	return strings.Compare(a.String(), b.String())
+}